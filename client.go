@@ -1,6 +1,8 @@
 package gnet
 
 import (
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/MaxnSter/gnet/packer"
 	"github.com/MaxnSter/gnet/pool"
 	"github.com/MaxnSter/gnet/util"
 	"net"
@@ -25,7 +27,7 @@ func NewClient(conn net.Conn, m Module, o Operator) NetClient {
 	}
 
 	id := util.GetUUID()
-	c.NetSession = newSession(id, conn, c, o)
+	c.NetSession = newSession(id, conn, c, m, o, true)
 	return c
 }
 
@@ -33,9 +35,9 @@ func (c *client) Run() {
 	c.Once.Do(func() {
 		go c.signal()
 
-		c.Pool().Run()
+		runModule(c.Module)
 		c.NetSession.Run()
-		c.Pool().Stop()
+		stopModule(c.Module)
 	})
 }
 
@@ -60,3 +62,13 @@ func (c *client) GetSession(id uint64) (NetSession, bool) {
 	}
 	return c, true
 }
+
+// Coder返回client连接使用的coder,可能是协商的结果而非module的coder
+func (c *client) Coder() codec.Coder {
+	return c.NetSession.Coder()
+}
+
+// Packer返回client连接使用的packer,而非module的packer原型
+func (c *client) Packer() packer.Packer {
+	return c.NetSession.Packer()
+}
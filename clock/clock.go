@@ -0,0 +1,77 @@
+package clock
+
+import "time"
+
+// Clock 是gnet中所有与时间相关的组件(timer, pool, session)获取时间的方式,
+// 默认为Real, 测试中可以替换为Fake, 手动推进时间
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Until(t time.Time) time.Duration
+
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 对应time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker 对应time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+var (
+	// Real 使用标准库time实现的Clock
+	Real Clock = realClock{}
+
+	_ Clock = realClock{}
+)
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Until(t time.Time) time.Duration        { return time.Until(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// OrReal c为nil时返回Real, 供各组件处理未设置的Clock
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
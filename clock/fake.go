@@ -0,0 +1,216 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// never 是溢出的deadline, Advance不会到达
+	never = time.Unix(1<<62, 0)
+
+	_ Clock  = (*Fake)(nil)
+	_ Timer  = (*fakeTimer)(nil)
+	_ Ticker = (*fakeTicker)(nil)
+)
+
+// Fake 是一个只能手动推进的Clock, 用于测试.
+// Advance或Set推进时间时, 到期的Timer, Ticker, After与Sleep按到期时间的顺序触发.
+// 与time.Timer相同, 触发时向容量为1的channel非阻塞地发送当前时间
+type Fake struct {
+	guard   sync.Mutex
+	now     time.Time
+	waiters []*waiter
+	changed chan struct{} // waiters变化时关闭, 用于BlockUntil
+}
+
+// waiter 是一个等待到期的Timer或Ticker
+type waiter struct {
+	deadline time.Time
+	period   time.Duration // Ticker的周期, Timer为0
+	ch       chan time.Time
+}
+
+// NewFake 返回一个当前时间为now的Fake
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+func (f *Fake) Now() time.Time {
+	f.guard.Lock()
+	defer f.guard.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) Until(t time.Time) time.Duration {
+	return t.Sub(f.Now())
+}
+
+// Sleep 阻塞直到时间被推进了d
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{f: f, w: &waiter{ch: make(chan time.Time, 1)}}
+	t.Reset(d)
+	return t
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	w := &waiter{period: d, ch: make(chan time.Time, 1)}
+	f.guard.Lock()
+	w.deadline = f.now.Add(d)
+	f.add(w)
+	f.guard.Unlock()
+	return &fakeTicker{f: f, w: w}
+}
+
+// Advance 将时间推进d
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set 将时间设置为t, t早于当前时间时不做任何事
+func (f *Fake) Set(t time.Time) {
+	f.guard.Lock()
+	defer f.guard.Unlock()
+
+	for len(f.waiters) > 0 && !f.waiters[0].deadline.After(t) {
+		w := f.waiters[0]
+		f.now = w.deadline
+		f.remove(w)
+
+		select {
+		case w.ch <- f.now:
+		default:
+		}
+
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+			f.add(w)
+		}
+	}
+
+	if t.After(f.now) {
+		f.now = t
+	}
+}
+
+// Waiters 返回当前等待中的Timer与Ticker(包括After与Sleep)的数量
+func (f *Fake) Waiters() int {
+	f.guard.Lock()
+	defer f.guard.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil 阻塞直到等待中的Timer与Ticker的数量为n,
+// 用于在Advance之前确认被测试的goroutine已经开始等待
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.guard.Lock()
+		if len(f.waiters) == n {
+			f.guard.Unlock()
+			return
+		}
+		changed := f.changed
+		f.guard.Unlock()
+
+		<-changed
+	}
+}
+
+// add 按deadline有序插入w, 调用方持有guard
+func (f *Fake) add(w *waiter) {
+	i := sort.Search(len(f.waiters), func(i int) bool {
+		return f.waiters[i].deadline.After(w.deadline)
+	})
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = w
+	f.notify()
+}
+
+// remove 移除w, w不在等待中时返回false, 调用方持有guard
+func (f *Fake) remove(w *waiter) bool {
+	for i := range f.waiters {
+		if f.waiters[i] == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.notify()
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+type fakeTimer struct {
+	f *Fake
+	w *waiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.f.guard.Lock()
+	defer t.f.guard.Unlock()
+	return t.f.remove(t.w)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	f := t.f
+	f.guard.Lock()
+	defer f.guard.Unlock()
+
+	active := f.remove(t.w)
+	if d <= 0 {
+		// 立即到期
+		select {
+		case t.w.ch <- f.now:
+		default:
+		}
+		return active
+	}
+
+	t.w.deadline = f.now.Add(d)
+	if t.w.deadline.Before(f.now) {
+		// 溢出, 视为永不到期, 但与time.Timer相同,仍然处于等待中
+		t.w.deadline = never
+	}
+	f.add(t.w)
+	return active
+}
+
+type fakeTicker struct {
+	f *Fake
+	w *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.f.guard.Lock()
+	defer t.f.guard.Unlock()
+	t.f.remove(t.w)
+}
@@ -0,0 +1,70 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake_Timer(t *testing.T) {
+	start := time.Unix(0, 0)
+	f := NewFake(start)
+
+	t1, t2 := f.NewTimer(2*time.Second), f.NewTimer(time.Second)
+	assert.Equal(t, 2, f.Waiters())
+
+	f.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-t2.C())
+	assert.Len(t, t1.C(), 0)
+	assert.False(t, t2.Stop())
+
+	assert.True(t, t1.Reset(3*time.Second))
+	f.Advance(2 * time.Second)
+	assert.Len(t, t1.C(), 0)
+	f.Advance(time.Second)
+	assert.Equal(t, start.Add(4*time.Second), <-t1.C())
+	assert.Equal(t, 0, f.Waiters())
+}
+
+func TestFake_Ticker(t *testing.T) {
+	start := time.Unix(0, 0)
+	f := NewFake(start)
+
+	ticker := f.NewTicker(time.Second)
+	f.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+
+	// 与time.Ticker相同, 来不及接收的tick被丢弃
+	f.Advance(3 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-ticker.C())
+	assert.Len(t, ticker.C(), 0)
+
+	ticker.Stop()
+	f.Advance(time.Second)
+	assert.Len(t, ticker.C(), 0)
+	assert.Equal(t, start.Add(5*time.Second), f.Now())
+}
+
+func TestFake_Sleep(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+
+	done := make(chan struct{})
+	go func() {
+		f.Sleep(time.Minute)
+		close(done)
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	<-done
+}
+
+func TestFake_Never(t *testing.T) {
+	f := NewFake(time.Now())
+
+	timer := f.NewTimer(time.Duration(1<<63 - 1))
+	f.Advance(24 * time.Hour * 365 * 100)
+	assert.Len(t, timer.C(), 0)
+	assert.True(t, timer.Stop())
+}
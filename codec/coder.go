@@ -12,3 +12,35 @@ type Coder interface {
 	// name of the coder
 	String() string
 }
+
+// SessionCoder 由需要为每个session维护独立状态的coder实现,例如gob的类型表.
+// 注册到codec中的实例作为原型, session建立时调用NewSessionCoder获取该session独享的实例
+type SessionCoder interface {
+	Coder
+	NewSessionCoder() Coder
+}
+
+// NewSession 若c实现了SessionCoder,返回一个新的实例,否则返回c本身
+func NewSession(c Coder) Coder {
+	if sc, ok := c.(SessionCoder); ok {
+		return sc.NewSessionCoder()
+	}
+	return c
+}
+
+// StreamCoder 由在一条连接上跨消息保存编解码状态的session coder实现, 例如gob的类型表.
+// 编码过的消息必须发送给对端, 对端发送的每一条消息都必须按顺序Decode,
+// 否则两端的状态不再一致, 该连接无法继续使用.
+// Decode的v为nil时丢弃消息的值, 只更新状态
+type StreamCoder interface {
+	Coder
+
+	// Stream 标记coder跨消息保存状态
+	Stream()
+}
+
+// IsStream 判断c是否为StreamCoder
+func IsStream(c Coder) bool {
+	_, ok := c.(StreamCoder)
+	return ok
+}
@@ -1,5 +1,11 @@
 package codec
 
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
 var (
 	coders = map[string]Coder{}
 )
@@ -19,3 +25,22 @@ func MustGetCoder(name string) Coder {
 		panic("Coder not register, name :" + name)
 	}
 }
+
+// GetCoder 获取指定名字对应的coder, 若未注册,返回错误
+func GetCoder(name string) (Coder, error) {
+	if c, ok := coders[name]; ok {
+		return c, nil
+	}
+
+	return nil, errors.New("Coder not register, name :" + name)
+}
+
+// ListCoders 返回所有已注册的coder名称,按名称排序
+func ListCoders() []string {
+	names := make([]string, 0, len(coders))
+	for name := range coders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,24 @@
+package codec_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/MaxnSter/gnet/codec"
+	_ "github.com/MaxnSter/gnet/codec/plugins/codec_json"
+	_ "github.com/MaxnSter/gnet/codec/plugins/codec_msgpack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCoder(t *testing.T) {
+	names := codec.ListCoders()
+	assert.Subset(t, names, []string{"json", "msgpack"})
+	assert.True(t, sort.StringsAreSorted(names))
+
+	c, err := codec.GetCoder("json")
+	assert.Nil(t, err)
+	assert.Equal(t, "json", c.String())
+
+	_, err = codec.GetCoder("not_exist")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,71 @@
+package codec_binary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MaxnSter/gnet/codec"
+)
+
+const (
+	// the name of coderBinary
+	coderBinaryTypeName = "binary"
+)
+
+var (
+	_ codec.Coder = (*coderBinary)(nil)
+)
+
+// coderBinary uses encoding/binary, only fixed-size values are supported:
+// bool, intN, uintN, floatN, complexN, and arrays, slices or structs of them
+type coderBinary struct {
+	order binary.ByteOrder
+}
+
+// return the name of coderBinary
+func (c *coderBinary) String() string {
+	return coderBinaryTypeName
+}
+
+// Encode encodes a fixed-size value into slice of bytes
+func (c *coderBinary) Encode(v interface{}) (data []byte, err error) {
+	size := binary.Size(v)
+	if size < 0 {
+		return nil, fmt.Errorf("%T is not a fixed-size value", v)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	if err = binary.Write(buf, c.order, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a fixed-size value from slice of bytes, v must be a pointer
+func (c *coderBinary) Decode(data []byte, v interface{}) error {
+	size := binary.Size(v)
+	if size < 0 {
+		return fmt.Errorf("%T is not a fixed-size value", v)
+	}
+	if size != len(data) {
+		return fmt.Errorf("size mismatch for %T, expect:%d, actual:%d", v, size, len(data))
+	}
+
+	return binary.Read(bytes.NewReader(data), c.order, v)
+}
+
+// register coderBinary
+func init() {
+	codec.RegisterCoder(coderBinaryTypeName, New())
+}
+
+// New 返回一个使用大端序(网络字节序)的coderBinary
+func New() codec.Coder {
+	return NewWithOrder(binary.BigEndian)
+}
+
+// NewWithOrder 返回一个使用指定字节序的coderBinary
+func NewWithOrder(order binary.ByteOrder) codec.Coder {
+	return &coderBinary{order: order}
+}
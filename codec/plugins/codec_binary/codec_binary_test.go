@@ -0,0 +1,36 @@
+package codec_binary
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoderBinary_EncodeAndDecode(t *testing.T) {
+	type Info struct {
+		Id    uint32
+		Temp  float32
+		Flags [2]uint8
+	}
+
+	coder := codec.MustGetCoder("binary")
+	data, err := coder.Encode(&Info{Id: 1, Temp: 21.5, Flags: [2]uint8{1, 2}})
+	assert.Nil(t, err, err)
+	assert.Len(t, data, 10)
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(data))
+
+	newInfo := new(Info)
+	err = coder.Decode(data, newInfo)
+	assert.Nil(t, err, err)
+	assert.Equal(t, uint32(1), newInfo.Id)
+	assert.Equal(t, float32(21.5), newInfo.Temp)
+	assert.Equal(t, [2]uint8{1, 2}, newInfo.Flags)
+
+	assert.NotNil(t, coder.Decode(data[:4], newInfo))
+
+	type Variable struct{ Msg string }
+	_, err = coder.Encode(&Variable{Msg: "binary"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,51 @@
+package codec_cbor
+
+import (
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	// the name of coderCbor
+	coderCborTypeName = "cbor"
+)
+
+var (
+	_ codec.Coder = (*coderCbor)(nil)
+
+	// time.Time编码为tag 1(epoch time),有小数部分时精确到微秒,与大部分设备端的实现兼容
+	encMode, _ = cbor.EncOptions{
+		Time:    cbor.TimeUnixDynamic,
+		TimeTag: cbor.EncTagRequired,
+	}.EncMode()
+
+	decMode, _ = cbor.DecOptions{}.DecMode()
+)
+
+// coderCbor uses cbor(RFC 7049) marshaler and unmarshaler,
+// struct tag uses `cbor:"name"`, falls back to `json:"name"`
+type coderCbor struct{}
+
+// return the name of coderCbor
+func (c *coderCbor) String() string {
+	return coderCborTypeName
+}
+
+// Encode encodes an object into slice of bytes
+func (c *coderCbor) Encode(v interface{}) (data []byte, err error) {
+	return encMode.Marshal(v)
+}
+
+// Decode decodes an object from slice of bytes
+func (c *coderCbor) Decode(data []byte, v interface{}) error {
+	return decMode.Unmarshal(data, v)
+}
+
+// register coderCbor
+func init() {
+	codec.RegisterCoder(coderCborTypeName, &coderCbor{})
+}
+
+func New() codec.Coder {
+	return &coderCbor{}
+}
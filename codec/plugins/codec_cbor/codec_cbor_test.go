@@ -0,0 +1,45 @@
+package codec_cbor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoderCbor_EncodeAndDecode(t *testing.T) {
+	type Info struct {
+		Id      uint32            `cbor:"id"`
+		Msg     string            `cbor:"msg"`
+		Payload []byte            `cbor:"payload"`
+		Attrs   map[string]int    `cbor:"attrs"`
+		At      time.Time         `cbor:"at"`
+		Extra   map[uint8]float64 `json:"extra"`
+	}
+
+	coder := codec.MustGetCoder("cbor")
+	info := &Info{
+		Id:      1,
+		Msg:     "cbor",
+		Payload: []byte{0x00, 0xff},
+		Attrs:   map[string]int{"temp": 21},
+		At:      time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC),
+		Extra:   map[uint8]float64{1: 0.5},
+	}
+
+	data, err := coder.Encode(info)
+	assert.Nil(t, err, err)
+
+	newInfo := new(Info)
+	err = coder.Decode(data, newInfo)
+	assert.Nil(t, err, err)
+	assert.NotNil(t, newInfo)
+
+	assert.Equal(t, uint32(1), newInfo.Id)
+	assert.Equal(t, "cbor", newInfo.Msg)
+	assert.Equal(t, info.Payload, newInfo.Payload)
+	assert.Equal(t, info.Attrs, newInfo.Attrs)
+	assert.True(t, info.At.Equal(newInfo.At))
+	assert.Equal(t, info.Extra, newInfo.Extra)
+}
@@ -0,0 +1,108 @@
+package codec_gob
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/MaxnSter/gnet/codec"
+)
+
+const (
+	// the name of coderGob
+	coderGobTypeName = "gob"
+)
+
+var (
+	_ codec.SessionCoder = (*coderGob)(nil)
+	_ codec.StreamCoder  = (*streamCoderGob)(nil)
+)
+
+// coderGob 是注册到codec中的原型, 每个session通过NewSessionCoder获得独享的streamCoderGob.
+// 在session之外直接使用时,每条消息都是一个独立的gob stream,携带完整的类型信息
+type coderGob struct{}
+
+// return the name of coderGob
+func (c *coderGob) String() string {
+	return coderGobTypeName
+}
+
+// Encode encodes an object into slice of bytes
+func (c *coderGob) Encode(v interface{}) (data []byte, err error) {
+	buf := &bytes.Buffer{}
+	if err = gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes an object from slice of bytes
+func (c *coderGob) Decode(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// NewSessionCoder 为session创建一个独立的streamCoderGob
+func (c *coderGob) NewSessionCoder() codec.Coder {
+	return NewStream()
+}
+
+// streamCoderGob 在一条连接上维护一对gob encoder/decoder,
+// 每个类型的类型信息只在第一次发送时携带,之后的消息只包含值.
+//
+// 连接两端必须都使用streamCoderGob,且一个实例只能用于一条连接;
+// 对端发送的每一条消息都必须按顺序Decode,否则decoder的类型表会错乱.
+// Encode只在writeLoop,Decode只在readLoop中调用,因此两个方向无需加锁
+type streamCoderGob struct {
+	wr  bytes.Buffer
+	enc *gob.Encoder
+
+	rd  bytes.Buffer
+	dec *gob.Decoder
+}
+
+// return the name of streamCoderGob
+func (c *streamCoderGob) String() string {
+	return coderGobTypeName
+}
+
+// Encode encodes an object into slice of bytes, omits type info already sent
+func (c *streamCoderGob) Encode(v interface{}) (data []byte, err error) {
+	c.wr.Reset()
+	if err = c.enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	data = make([]byte, c.wr.Len())
+	copy(data, c.wr.Bytes())
+	return data, nil
+}
+
+// Decode decodes an object from slice of bytes, v为nil时只读取类型信息并丢弃值.
+// 解码失败时丢弃该消息剩余的数据, 避免影响之后的消息
+func (c *streamCoderGob) Decode(data []byte, v interface{}) error {
+	c.rd.Write(data)
+	if err := c.dec.Decode(v); err != nil {
+		c.rd.Reset()
+		return err
+	}
+	return nil
+}
+
+// Stream 见codec.StreamCoder
+func (c *streamCoderGob) Stream() {}
+
+// register coderGob
+func init() {
+	codec.RegisterCoder(coderGobTypeName, &coderGob{})
+}
+
+func New() codec.Coder {
+	return &coderGob{}
+}
+
+// NewStream 返回一个只能用于一条连接的gob coder, 见streamCoderGob
+func NewStream() codec.Coder {
+	c := &streamCoderGob{}
+	c.enc = gob.NewEncoder(&c.wr)
+	c.dec = gob.NewDecoder(&c.rd)
+	return c
+}
@@ -0,0 +1,89 @@
+package codec_gob
+
+import (
+	"testing"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+type Info struct {
+	Id  uint32
+	Msg string
+}
+
+func TestCoderGob_EncodeAndDecode(t *testing.T) {
+	coder := codec.MustGetCoder("gob")
+
+	data, err := coder.Encode(&Info{Id: 1, Msg: "gob"})
+	assert.Nil(t, err, err)
+
+	newInfo := new(Info)
+	err = coder.Decode(data, newInfo)
+	assert.Nil(t, err, err)
+	assert.NotNil(t, newInfo)
+
+	assert.Equal(t, uint32(1), newInfo.Id)
+	assert.Equal(t, "gob", newInfo.Msg)
+}
+
+func TestStreamCoderGob_EncodeAndDecode(t *testing.T) {
+	sender, receiver := NewStream(), NewStream()
+
+	first, err := sender.Encode(&Info{Id: 1, Msg: "first"})
+	assert.Nil(t, err, err)
+	second, err := sender.Encode(&Info{Id: 2, Msg: "first"})
+	assert.Nil(t, err, err)
+
+	// 第二条消息不再携带类型信息
+	assert.True(t, len(second) < len(first))
+
+	for i, data := range [][]byte{first, second} {
+		newInfo := new(Info)
+		err = receiver.Decode(data, newInfo)
+		assert.Nil(t, err, err)
+		assert.Equal(t, uint32(i+1), newInfo.Id)
+	}
+}
+
+func TestCoderGob_NewSessionCoder(t *testing.T) {
+	proto := codec.MustGetCoder("gob")
+
+	s1, s2 := codec.NewSession(proto), codec.NewSession(proto)
+	assert.True(t, s1 != s2)
+
+	data, err := s1.Encode(&Info{Id: 1, Msg: "session"})
+	assert.Nil(t, err, err)
+
+	newInfo := new(Info)
+	assert.Nil(t, codec.NewSession(proto).Decode(data, newInfo))
+	assert.Equal(t, "session", newInfo.Msg)
+}
+
+func TestStreamCoderGob_Skip(t *testing.T) {
+	sender, receiver := NewStream(), NewStream()
+
+	first, err := sender.Encode(&Info{Id: 1, Msg: "first"})
+	assert.Nil(t, err, err)
+	second, err := sender.Encode(&Info{Id: 2, Msg: "second"})
+	assert.Nil(t, err, err)
+
+	// 丢弃第一条消息的值, 类型信息仍然被记录
+	assert.Nil(t, receiver.Decode(first, nil))
+	newInfo := new(Info)
+	assert.Nil(t, receiver.Decode(second, newInfo))
+	assert.Equal(t, "second", newInfo.Msg)
+}
+
+func TestStreamCoderGob_DecodeError(t *testing.T) {
+	sender, receiver := NewStream(), NewStream()
+
+	// 类型不匹配时消息只被读取了一部分, 剩余的数据不会残留在decoder中
+	assert.NotNil(t, receiver.Decode([]byte{0x01, 0x02, 0x07, 0x01}, new(Info)))
+
+	data, err := sender.Encode(&Info{Id: 1, Msg: "after"})
+	assert.Nil(t, err, err)
+	newInfo := new(Info)
+	assert.Nil(t, receiver.Decode(data, newInfo))
+	assert.Equal(t, "after", newInfo.Msg)
+}
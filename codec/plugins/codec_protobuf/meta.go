@@ -0,0 +1,65 @@
+package codec_protobuf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+
+	"github.com/MaxnSter/gnet/meta"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/pkg/errors"
+)
+
+// RegisterFileMetas 注册proto文件(protoc生成代码时的文件名,例如info.proto)中定义的所有消息,
+// 包括嵌套消息. msgId为消息全名(例如test.Info)的meta.NameID,与生成代码的语言无关
+func RegisterFileMetas(filename string) ([]meta.Meta, error) {
+	gz := proto.FileDescriptor(filename)
+	if gz == nil {
+		return nil, errors.Errorf("proto file not register, name :%s", filename)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, errors.Wrapf(err, "bad file descriptor, name :%s", filename)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bad file descriptor, name :%s", filename)
+	}
+
+	fd := &descriptor.FileDescriptorProto{}
+	if err = proto.Unmarshal(b, fd); err != nil {
+		return nil, errors.Wrapf(err, "bad file descriptor, name :%s", filename)
+	}
+
+	var metas []meta.Meta
+	if err = registerMessages(fd.GetPackage(), fd.GetMessageType(), &metas); err != nil {
+		return metas, err
+	}
+	return metas, nil
+}
+
+func registerMessages(prefix string, msgs []*descriptor.DescriptorProto, metas *[]meta.Meta) error {
+	for _, msg := range msgs {
+		name := msg.GetName()
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		// map字段生成的entry消息没有对应的go类型
+		if t := proto.MessageType(name); t != nil && !msg.GetOptions().GetMapEntry() {
+			m, err := meta.TryRegisterMsgName(name, t)
+			if err != nil {
+				return err
+			}
+			*metas = append(*metas, m)
+		}
+
+		if err := registerMessages(name, msg.GetNestedType(), metas); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
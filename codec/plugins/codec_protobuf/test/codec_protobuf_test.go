@@ -1,12 +1,14 @@
 package test
 
 import (
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/MaxnSter/gnet/codec"
+	"github.com/MaxnSter/gnet/codec/plugins/codec_protobuf"
+	"github.com/MaxnSter/gnet/meta"
 	"github.com/stretchr/testify/assert"
-
-	_ "github.com/MaxnSter/gnet/codec/codec_protobuf"
 )
 
 func TestEncodeAndDecode(t *testing.T) {
@@ -24,3 +26,27 @@ func TestEncodeAndDecode(t *testing.T) {
 	assert.Equal(t, uint32(1), newInfo.Id)
 
 }
+
+// 注册无法撤销, 每个进程只注册一次info.proto(go test -count=n)
+var (
+	infoOnce  sync.Once
+	infoMetas []meta.Meta
+	infoErr   error
+)
+
+func TestRegisterFileMetas(t *testing.T) {
+	infoOnce.Do(func() {
+		infoMetas, infoErr = codec_protobuf.RegisterFileMetas("info.proto")
+	})
+	metas, err := infoMetas, infoErr
+	assert.Nil(t, err, err)
+	assert.Len(t, metas, 1)
+	assert.Equal(t, meta.NameID("test.Info"), metas[0].Identify())
+
+	m, err := meta.GetMsgMetaByType(reflect.TypeOf(&Info{}))
+	assert.Nil(t, err, err)
+	assert.IsType(t, &Info{}, m.New())
+
+	_, err = codec_protobuf.RegisterFileMetas("unknown.proto")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,261 @@
+// Package config 从配置文件与环境变量中创建gnet server.
+//
+// 配置文件支持json与yaml, 根据扩展名区分, 例如:
+//
+//	listen: ":2007"
+//	transport: tcp
+//	pool:
+//	  type: poolRaceSelf
+//	  workers: 32
+//	  queue_size: 512
+//	  max_queue_size: 4096
+//	coder: json
+//	packer: lv
+//	timer: timer_heap
+//	idle_timeout: 60s
+//	max_sessions: 10000
+//
+// 组件所在的plugin包需要被import, 以完成注册
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MaxnSter/gnet"
+	"github.com/MaxnSter/gnet/net/plugins/ws_listener"
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TransportTCP  = "tcp"
+	TransportWS   = "ws"
+	TransportUnix = "unix"
+)
+
+// EnvPrefix 是ApplyEnv使用的默认环境变量前缀
+const EnvPrefix = "GNET_"
+
+// Config 描述一个gnet server
+type Config struct {
+	// Listen 监听地址, unix时为socket文件路径
+	Listen string `json:"listen" yaml:"listen" env:"LISTEN"`
+
+	// Transport 传输层, 可选tcp, ws与unix, 默认tcp
+	Transport string `json:"transport" yaml:"transport" env:"TRANSPORT"`
+
+	// WSPath websocket升级的http路径, 默认为"/"
+	WSPath string `json:"ws_path" yaml:"ws_path" env:"WS_PATH"`
+
+	Pool PoolConfig `json:"pool" yaml:"pool" env:"POOL_"`
+
+	// Coder coder的名称, 例如json
+	Coder string `json:"coder" yaml:"coder" env:"CODER"`
+
+	// Packer packer的名称, 例如lv
+	Packer string `json:"packer" yaml:"packer" env:"PACKER"`
+
+	// Timer timer的名称, 为空时不设置timer
+	Timer string `json:"timer" yaml:"timer" env:"TIMER"`
+
+	// HandshakeTimeout 见gnet.Timeouts
+	HandshakeTimeout Duration `json:"handshake_timeout" yaml:"handshake_timeout" env:"HANDSHAKE_TIMEOUT"`
+
+	// IdleTimeout 见gnet.Timeouts
+	IdleTimeout Duration `json:"idle_timeout" yaml:"idle_timeout" env:"IDLE_TIMEOUT"`
+
+	// StopGrace 见gnet.Timeouts
+	StopGrace Duration `json:"stop_grace" yaml:"stop_grace" env:"STOP_GRACE"`
+
+	// MaxSessions 同时存在的session数量上限, 0表示不限制
+	MaxSessions int `json:"max_sessions" yaml:"max_sessions" env:"MAX_SESSIONS"`
+}
+
+// PoolConfig 描述server使用的pool
+type PoolConfig struct {
+	// Type pool的名称, 例如poolRaceOther
+	Type string `json:"type" yaml:"type" env:"TYPE"`
+
+	// 以下字段见pool.Config, 为0时使用pool的默认值
+	Workers       int      `json:"workers" yaml:"workers" env:"WORKERS"`
+	QueueSize     int      `json:"queue_size" yaml:"queue_size" env:"QUEUE_SIZE"`
+	MaxQueueSize  int      `json:"max_queue_size" yaml:"max_queue_size" env:"MAX_QUEUE_SIZE"`
+	MaxGoroutines int      `json:"max_goroutines" yaml:"max_goroutines" env:"MAX_GOROUTINES"`
+	IdleTimeout   Duration `json:"idle_timeout" yaml:"idle_timeout" env:"IDLE_TIMEOUT"`
+}
+
+// Duration 是可以用"10s", "1m30s"等字符串表示的time.Duration
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load 读取path中的配置, 根据扩展名(.json, .yaml, .yml)选择格式,
+// 然后使用EnvPrefix应用环境变量并检查配置
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "load config")
+	}
+
+	c := &Config{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(c)
+	case ".yaml", ".yml":
+		d := yaml.NewDecoder(bytes.NewReader(b))
+		d.KnownFields(true)
+		err = d.Decode(c)
+	default:
+		return nil, errors.Errorf("load config %s: unsupported format %q", path, ext)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "load config %s", path)
+	}
+
+	if err = ApplyEnv(c, EnvPrefix); err != nil {
+		return nil, err
+	}
+	if err = c.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "load config %s", path)
+	}
+	return c, nil
+}
+
+// Validate 填充默认值并检查配置, 所有的问题一起通过错误返回.
+// 组件的名称由Module检查
+func (c *Config) Validate() error {
+	if c.Transport == "" {
+		c.Transport = TransportTCP
+	}
+	if c.WSPath == "" {
+		c.WSPath = "/"
+	}
+
+	var problems []string
+	if c.Listen == "" {
+		problems = append(problems, "listen is required")
+	}
+	switch c.Transport {
+	case TransportTCP, TransportWS, TransportUnix:
+	default:
+		problems = append(problems, "unknown transport "+c.Transport)
+	}
+	if c.Pool.Type == "" {
+		problems = append(problems, "pool.type is required")
+	}
+	if c.Coder == "" {
+		problems = append(problems, "coder is required")
+	}
+	if c.Packer == "" {
+		problems = append(problems, "packer is required")
+	}
+	if c.HandshakeTimeout < 0 || c.IdleTimeout < 0 || c.StopGrace < 0 {
+		problems = append(problems, "negative timeout")
+	}
+	if c.MaxSessions < 0 {
+		problems = append(problems, "negative max_sessions")
+	}
+	if c.Pool.Workers < 0 || c.Pool.QueueSize < 0 || c.Pool.MaxQueueSize < 0 || c.Pool.MaxGoroutines < 0 || c.Pool.IdleTimeout < 0 {
+		problems = append(problems, "negative pool size")
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Listener 根据Transport在Listen上监听
+func (c *Config) Listener() (net.Listener, error) {
+	switch c.Transport {
+	case TransportWS:
+		return ws_listener.Listen(c.Listen, c.WSPath, websocket.Upgrader{})
+	case TransportUnix:
+		return net.Listen("unix", c.Listen)
+	default:
+		return net.Listen("tcp", c.Listen)
+	}
+}
+
+// Module 从各组件的注册表中创建Module, 见gnet.BuildModule
+func (c *Config) Module(opts ...func(gnet.Module)) (gnet.Module, error) {
+	return gnet.BuildModule(gnet.ModuleConfig{
+		Pool: c.Pool.Type,
+		PoolConfig: pool.Config{
+			Workers:       c.Pool.Workers,
+			QueueSize:     c.Pool.QueueSize,
+			MaxQueueSize:  c.Pool.MaxQueueSize,
+			MaxGoroutines: c.Pool.MaxGoroutines,
+			IdleTimeout:   time.Duration(c.Pool.IdleTimeout),
+		},
+		Coder:  c.Coder,
+		Packer: c.Packer,
+		Timer:  c.Timer,
+	}, opts...)
+}
+
+// Timeouts 返回配置中session的超时时间
+func (c *Config) Timeouts() gnet.Timeouts {
+	return gnet.Timeouts{
+		Handshake: time.Duration(c.HandshakeTimeout),
+		Idle:      time.Duration(c.IdleTimeout),
+		StopGrace: time.Duration(c.StopGrace),
+	}
+}
+
+// NewServer 根据c创建server, opts用于设置配置文件无法描述的operator选项, 例如gnet.WithMeta
+func NewServer(c *Config, cb gnet.Callback, opts ...func(gnet.Operator)) (gnet.NetServer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 先监听, 监听失败时不会创建任何组件
+	l, err := c.Listener()
+	if err != nil {
+		return nil, errors.Wrapf(err, "listen %s %s", c.Transport, c.Listen)
+	}
+
+	m, err := c.Module()
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	opts = append([]func(gnet.Operator){gnet.WithTimeouts(c.Timeouts())}, opts...)
+	o := gnet.NewOperator(m, cb, opts...)
+	return gnet.NewServer(l, m, o, gnet.WithMaxSessions(c.MaxSessions)), nil
+}
+
+// LoadServer 读取path中的配置并创建server, 见Load与NewServer
+func LoadServer(path string, cb gnet.Callback, opts ...func(gnet.Operator)) (gnet.NetServer, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewServer(c, cb, opts...)
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet"
+	_ "github.com/MaxnSter/gnet/codec/plugins/codec_json"
+	_ "github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	_ "github.com/MaxnSter/gnet/pool/plugins/pool_race_other"
+	"github.com/stretchr/testify/assert"
+)
+
+var dir string
+
+func TestMain(m *testing.M) {
+	var err error
+	if dir, err = ioutil.TempDir("", "gnet_config"); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := writeConfig(t, "server.yaml", `
+listen: ":2007"
+pool:
+  type: poolRaceSelf
+  workers: 8
+  max_queue_size: 4096
+  idle_timeout: 30s
+coder: json
+packer: lv
+timer: timer_heap
+idle_timeout: 1m30s
+max_sessions: 100
+`)
+
+	c, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ":2007", c.Listen)
+	assert.Equal(t, TransportTCP, c.Transport)
+	assert.Equal(t, "poolRaceSelf", c.Pool.Type)
+	assert.Equal(t, 8, c.Pool.Workers)
+	assert.Equal(t, 4096, c.Pool.MaxQueueSize)
+	assert.Equal(t, Duration(30*time.Second), c.Pool.IdleTimeout)
+	assert.Equal(t, "timer_heap", c.Timer)
+	assert.Equal(t, 90*time.Second, c.Timeouts().Idle)
+	assert.Equal(t, 100, c.MaxSessions)
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := writeConfig(t, "server.json", `{
+	"listen": "/tmp/gnet.sock",
+	"transport": "unix",
+	"pool": {"type": "poolNoRace"},
+	"coder": "json",
+	"packer": "lv",
+	"handshake_timeout": "3s"
+}`)
+
+	c, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, TransportUnix, c.Transport)
+	assert.Equal(t, 3*time.Second, c.Timeouts().Handshake)
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	_, err := Load(writeConfig(t, "server.json", `{"listen": ":2007", "lisen": ":2008"}`))
+	assert.Error(t, err)
+
+	_, err = Load(writeConfig(t, "server.yml", "listen: \":2007\"\nlisen: \":2008\"\n"))
+	assert.Error(t, err)
+
+	_, err = Load(writeConfig(t, "server.toml", ""))
+	assert.Error(t, err)
+}
+
+func TestApplyEnv(t *testing.T) {
+	os.Setenv("TEST_GNET_LISTEN", ":3000")
+	os.Setenv("TEST_GNET_POOL_TYPE", "poolNoRace")
+	os.Setenv("TEST_GNET_POOL_MAX_GOROUTINES", "1000")
+	os.Setenv("TEST_GNET_IDLE_TIMEOUT", "5s")
+	os.Setenv("TEST_GNET_MAX_SESSIONS", "7")
+	defer func() {
+		for _, k := range []string{"LISTEN", "POOL_TYPE", "POOL_MAX_GOROUTINES", "IDLE_TIMEOUT", "MAX_SESSIONS"} {
+			os.Unsetenv("TEST_GNET_" + k)
+		}
+	}()
+
+	c := &Config{Listen: ":2007", Coder: "json"}
+	assert.NoError(t, ApplyEnv(c, "TEST_GNET_"))
+	assert.Equal(t, ":3000", c.Listen)
+	assert.Equal(t, "poolNoRace", c.Pool.Type)
+	assert.Equal(t, 1000, c.Pool.MaxGoroutines)
+	assert.Equal(t, Duration(5*time.Second), c.IdleTimeout)
+	assert.Equal(t, 7, c.MaxSessions)
+	assert.Equal(t, "json", c.Coder)
+
+	os.Setenv("TEST_GNET_MAX_SESSIONS", "many")
+	assert.Error(t, ApplyEnv(c, "TEST_GNET_"))
+}
+
+func TestValidate(t *testing.T) {
+	c := &Config{Transport: "udp", MaxSessions: -1}
+	err := c.Validate()
+	assert.Error(t, err)
+	for _, s := range []string{"listen", "transport udp", "pool.type", "coder", "packer", "max_sessions"} {
+		assert.Contains(t, err.Error(), s)
+	}
+
+	c = &Config{Listen: ":2007", Pool: PoolConfig{Type: "poolNoRace"}, Coder: "json", Packer: "lv"}
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, TransportTCP, c.Transport)
+	assert.Equal(t, "/", c.WSPath)
+}
+
+func TestNewServer(t *testing.T) {
+	// 占用一个空闲端口后释放, 供下面的server使用
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	c := &Config{Listen: addr, Pool: PoolConfig{Type: "poolRaceOther"}, Coder: "json", Packer: "lv"}
+	svc, err := NewServer(c, gnet.Callback{})
+	if assert.NoError(t, err) {
+		svc.Stop()
+	}
+
+	// 组件名称错误时, 已经打开的listener被关闭
+	c.Coder = "not_exist"
+	_, err = NewServer(c, gnet.Callback{})
+	assert.Error(t, err)
+
+	l, err = net.Listen("tcp", addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close()
+
+	// 地址被占用时直接返回监听错误
+	c.Coder = "json"
+	_, err = NewServer(c, gnet.Callback{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "listen tcp "+addr)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// ApplyEnv 使用环境变量覆盖c中的字段, 变量名为prefix加上字段的env tag,
+// 嵌套结构体的tag作为前缀, 例如GNET_POOL_TYPE覆盖Pool.Type.
+// 未设置的变量不会修改对应的字段
+func ApplyEnv(c *Config, prefix string) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), prefix)
+}
+
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		name, f := prefix+tag, v.Field(i)
+
+		if f.Kind() == reflect.Struct {
+			if err := applyEnv(f, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case f.Type() == durationType:
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return errors.Wrapf(err, "env %s", name)
+			}
+			f.SetInt(int64(d))
+		case f.Kind() == reflect.String:
+			f.SetString(s)
+		case f.Kind() == reflect.Int:
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return errors.Wrapf(err, "env %s", name)
+			}
+			f.SetInt(int64(n))
+		default:
+			return errors.Errorf("env %s: unsupported field type %s", name, f.Type())
+		}
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package gnet
 
+import "github.com/MaxnSter/gnet/packer"
+
 // Event 是onMessage回调中传入的参数
 type Event interface {
 	// Session返回本条消息对应的NetSession
@@ -7,11 +9,15 @@ type Event interface {
 
 	// Message返回经过unPack,decode之后的消息
 	Message() interface{}
+
+	// Header返回本条消息的消息类型头,packer不带消息类型头时为零值
+	Header() packer.Header
 }
 
 type eventWrapper struct {
 	eventSession NetSession  //本条消息对应的NetSession
 	msg          interface{} //经过UnPack,decode之后的消息
+	header       packer.Header
 }
 
 // Session 返回本条消息对应的NetSession
@@ -23,3 +29,8 @@ func (msg *eventWrapper) Session() NetSession {
 func (msg *eventWrapper) Message() interface{} {
 	return msg.msg
 }
+
+// Header 返回本条消息的消息类型头
+func (msg *eventWrapper) Header() packer.Header {
+	return msg.header
+}
@@ -0,0 +1,12 @@
+package meta
+
+// Envelope 显式指定消息的msgId, 用于未在meta中注册类型的消息,
+// 或同一个类型需要以不同msgId发送的场景. 编码时只对Payload编码.
+// Topic, Flags, Seq只在packer的消息类型头支持时写入,见packer.HeaderCodec
+type Envelope struct {
+	ID      uint32
+	Topic   string
+	Flags   uint16
+	Seq     uint32
+	Payload interface{}
+}
@@ -0,0 +1,93 @@
+package meta
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/pkg/errors"
+)
+
+// CoderMeta 是绑定了coder的meta, operator使用该coder而非module的coder编解码此类消息
+type CoderMeta interface {
+	Meta
+	Coder() codec.Coder
+}
+
+type coderMetaWrapper struct {
+	metaWrapper
+	coder codec.Coder
+}
+
+func (m coderMetaWrapper) Coder() codec.Coder {
+	return m.coder
+}
+
+// NewWithCoder 与New相同,但该消息使用指定的coder编解码
+func NewWithCoder(id uint32, t reflect.Type, c codec.Coder) Meta {
+	return coderMetaWrapper{
+		metaWrapper: metaWrapper{id: id, pType: t},
+		coder:       c,
+	}
+}
+
+type coderRange struct {
+	min, max uint32
+	coder    codec.Coder
+}
+
+var (
+	rangeGuard sync.RWMutex
+	ranges     []coderRange
+)
+
+// BindCoder 将[min, max]范围内的msgId绑定到指定的coder.
+// 范围与已绑定的范围重叠时返回错误
+func BindCoder(min, max uint32, c codec.Coder) error {
+	if min > max {
+		return errors.Errorf("bad msgId range [%d, %d]", min, max)
+	}
+
+	rangeGuard.Lock()
+	defer rangeGuard.Unlock()
+
+	for _, r := range ranges {
+		if min <= r.max && r.min <= max {
+			return errors.Errorf("msgId range [%d, %d] overlaps [%d, %d] bound to %s",
+				min, max, r.min, r.max, r.coder)
+		}
+	}
+
+	ranges = append(ranges, coderRange{min: min, max: max, coder: c})
+	return nil
+}
+
+// CoderOf 返回m绑定的coder:先查m自身(见CoderMeta),再查m的msgId所在范围.
+// 未绑定时返回nil,由调用方使用module的coder
+func CoderOf(m Meta) codec.Coder {
+	if cm, ok := m.(CoderMeta); ok && cm.Coder() != nil {
+		return cm.Coder()
+	}
+	return coderOfRange(m.Identify())
+}
+
+// GetCoder 返回msgId绑定的coder,msgId未注册meta时只查范围.
+// 未绑定时返回nil
+func GetCoder(id uint32) codec.Coder {
+	if m, err := GetMsgMeta(id); err == nil {
+		return CoderOf(m)
+	}
+	return coderOfRange(id)
+}
+
+func coderOfRange(id uint32) codec.Coder {
+	rangeGuard.RLock()
+	defer rangeGuard.RUnlock()
+
+	for _, r := range ranges {
+		if r.min <= id && id <= r.max {
+			return r.coder
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package meta
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+type dummyCoder struct{ name string }
+
+func (c *dummyCoder) Encode(v interface{}) ([]byte, error)    { return nil, nil }
+func (c *dummyCoder) Decode(data []byte, v interface{}) error { return nil }
+func (c *dummyCoder) String() string                          { return c.name }
+
+var _ codec.Coder = (*dummyCoder)(nil)
+
+// unbindCoder 删除以min开始的coder范围, 见unregister
+func unbindCoder(min uint32) {
+	rangeGuard.Lock()
+	defer rangeGuard.Unlock()
+
+	for i, r := range ranges {
+		if r.min == min {
+			ranges = append(ranges[:i], ranges[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestCoderOf(t *testing.T) {
+	type Blob struct{}
+	type Control struct{}
+	defer unbindCoder(5000)
+	defer unregister(5001)
+
+	raw, ctl := &dummyCoder{"raw"}, &dummyCoder{"ctl"}
+	assert.Nil(t, BindCoder(5000, 5999, raw))
+	assert.NotNil(t, BindCoder(5999, 6000, raw))
+	assert.NotNil(t, BindCoder(10, 1, raw))
+
+	m := NewWithCoder(5001, reflect.TypeOf(Control{}), ctl)
+	assert.Equal(t, ctl, CoderOf(m))
+	assert.Equal(t, raw, CoderOf(New(5002, reflect.TypeOf(Blob{}))))
+	assert.Nil(t, CoderOf(New(6000, reflect.TypeOf(Blob{}))))
+
+	assert.Nil(t, TryRegisterMsgMeta(m))
+	assert.Equal(t, ctl, GetCoder(5001))
+	assert.Equal(t, raw, GetCoder(5999))
+	assert.Nil(t, GetCoder(4999))
+}
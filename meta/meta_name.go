@@ -0,0 +1,77 @@
+package meta
+
+import (
+	"hash/fnv"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// NameID 根据消息的全限定名计算msgId(FNV-1a 32bit).
+// 同一个名字在任何进程,任何语言中得到的id都相同,
+// 不同名字的碰撞在注册时由TryRegisterMsgMeta检测
+func NameID(name string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return h.Sum32()
+}
+
+// TypeName 返回类型的全限定名,例如github.com/MaxnSter/gnet/meta.Login, T与*T相同
+func TypeName(t reflect.Type) string {
+	t = indirect(t)
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// RegisterMsgType 以类型全限定名的NameID作为msgId注册一个meta.
+// 如果id碰撞或类型已注册,则panic
+func RegisterMsgType(t reflect.Type) Meta {
+	m, err := TryRegisterMsgType(t)
+	if err != nil {
+		panic(err.Error())
+	}
+	return m
+}
+
+// TryRegisterMsgType 与RegisterMsgType相同,但id碰撞或类型已注册时返回错误
+func TryRegisterMsgType(t reflect.Type) (Meta, error) {
+	if indirect(t).Name() == "" {
+		return nil, errors.Errorf("can not register unnamed type :%s", t)
+	}
+
+	return TryRegisterMsgName(TypeName(t), t)
+}
+
+// TryRegisterMsgName 以name的NameID作为msgId注册一个meta.
+// 用于跨语言的场景,例如protobuf消息的全名
+func TryRegisterMsgName(name string, t reflect.Type) (Meta, error) {
+	m := New(NameID(name), t)
+
+	guard.RLock()
+	old, ok := metas[m.Identify()]
+	guard.RUnlock()
+	if ok && indirect(old.Type()) != indirect(t) {
+		return nil, errors.Errorf("message_meta id collision, id :%d, name :%s, type :%s, registered type :%s",
+			m.Identify(), name, t, old.Type())
+	}
+
+	if err := TryRegisterMsgMeta(m); err != nil {
+		return nil, err
+	}
+
+	guard.Lock()
+	names[m.Identify()] = name
+	guard.Unlock()
+	return m, nil
+}
+
+// GetMsgName 返回通过名字注册的meta对应的名字
+func GetMsgName(id uint32) (string, bool) {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	name, ok := names[id]
+	return name, ok
+}
@@ -1,28 +1,115 @@
 package meta
 
-import "fmt"
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
 
 var (
-	metas = map[uint32]Meta{}
+	guard       sync.RWMutex
+	metas       = map[uint32]Meta{}
+	types       = map[reflect.Type]Meta{}
+	names       = map[uint32]string{}
+	strictTypes bool
 )
 
+// SetStrictTypes 设置是否拒绝以不同id重复注册同一个类型, 默认不拒绝.
+// 不拒绝时, GetMsgMetaByType返回该类型第一个注册的meta
+func SetStrictTypes(strict bool) {
+	guard.Lock()
+	strictTypes = strict
+	guard.Unlock()
+}
+
+// indirect 对指针类型取其元素类型,使T与*T对应同一个meta
+func indirect(t reflect.Type) reflect.Type {
+	if t != nil && t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
 // RegisterMsgMeta 注册一个meta
-// 如果meta已存在或meda id重复,则panic
+// 如果meta已存在或meda id重复,则panic, 类型重复的处理见SetStrictTypes
 func RegisterMsgMeta(m Meta) {
+	if err := TryRegisterMsgMeta(m); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryRegisterMsgMeta 与RegisterMsgMeta相同,但id重复时返回错误.
+// 开启SetStrictTypes时, 类型重复同样返回错误
+func TryRegisterMsgMeta(m Meta) error {
+	guard.Lock()
+	defer guard.Unlock()
+
 	if _, ok := metas[m.Identify()]; ok {
-		panic(fmt.Sprintf("dup register message_meta meta, id :%d", m.Identify()))
+		return errors.Errorf("dup register message_meta meta, id :%d", m.Identify())
+	}
+
+	t := indirect(m.Type())
+	old, dup := types[t]
+	if dup && strictTypes {
+		return errors.Errorf("dup register message_meta meta, type :%s, id :%d", t, old.Identify())
 	}
 
 	metas[m.Identify()] = m
+	if !dup {
+		types[t] = m
+	}
+	return nil
 }
 
 // MustGetMsgMeta 获取指定id对应的meta.
 // 若未注册,则panic
 func MustGetMsgMeta(id uint32) Meta {
+	m, err := GetMsgMeta(id)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return m
+}
+
+// GetMsgMeta 获取指定id对应的meta,若未注册,返回错误
+func GetMsgMeta(id uint32) (Meta, error) {
+	guard.RLock()
+	defer guard.RUnlock()
 
 	if m, ok := metas[id]; ok {
-		return m
+		return m, nil
+	}
+
+	return nil, errors.Errorf("message_meta meta not register , id :%d", id)
+}
+
+// GetMsgMetaByType 获取指定类型对应的meta, T与*T对应同一个meta.
+// 若未注册,返回错误
+func GetMsgMetaByType(t reflect.Type) (Meta, error) {
+	guard.RLock()
+	defer guard.RUnlock()
+
+	if m, ok := types[indirect(t)]; ok {
+		return m, nil
+	}
+
+	return nil, errors.Errorf("message_meta meta not register , type :%s", t)
+}
+
+// ListMsgMeta 返回所有已注册的meta,按id升序排列
+func ListMsgMeta() []Meta {
+	guard.RLock()
+	list := make([]Meta, 0, len(metas))
+	for _, m := range metas {
+		list = append(list, m)
 	}
+	guard.RUnlock()
 
-	panic(fmt.Sprintf("message_meta meta not register , id :%d", id))
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Identify() < list[j].Identify()
+	})
+	return list
 }
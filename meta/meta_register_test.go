@@ -0,0 +1,99 @@
+package meta
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unregister 从全局注册表中删除ids对应的meta, 使测试可以重复运行(go test -count=n)
+func unregister(ids ...uint32) {
+	guard.Lock()
+	defer guard.Unlock()
+
+	for _, id := range ids {
+		m, ok := metas[id]
+		if !ok {
+			continue
+		}
+		delete(metas, id)
+		delete(names, id)
+		if t := indirect(m.Type()); types[t] != nil && types[t].Identify() == id {
+			delete(types, t)
+		}
+	}
+}
+
+func TestRegisterMsgMeta(t *testing.T) {
+	type Login struct{ Name string }
+	type Logout struct{}
+	defer unregister(1000, 1001)
+
+	assert.Nil(t, TryRegisterMsgMeta(New(1001, reflect.TypeOf((*Login)(nil)))))
+	assert.Nil(t, TryRegisterMsgMeta(New(1000, reflect.TypeOf(Logout{}))))
+
+	// id重复
+	assert.NotNil(t, TryRegisterMsgMeta(New(1001, reflect.TypeOf(Logout{}))))
+	assert.Panics(t, func() { RegisterMsgMeta(New(1000, reflect.TypeOf(0))) })
+
+	m, err := GetMsgMeta(1001)
+	assert.Nil(t, err, err)
+	assert.IsType(t, &Login{}, m.New())
+
+	_, err = GetMsgMeta(9999)
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { MustGetMsgMeta(9999) })
+
+	m, err = GetMsgMetaByType(reflect.TypeOf(&Logout{}))
+	assert.Nil(t, err, err)
+	assert.Equal(t, uint32(1000), m.Identify())
+
+	// 其他测试同样会注册meta,只检查顺序
+	list := ListMsgMeta()
+	for i := 1; i < len(list); i++ {
+		assert.True(t, list[i-1].Identify() < list[i].Identify())
+	}
+	assert.Contains(t, list, m)
+}
+
+func TestRegisterMsgMeta_DupType(t *testing.T) {
+	type Ping struct{}
+	type Pong struct{}
+	defer unregister(2000, 2001, 2002, 2003, 2004)
+
+	// 默认允许以不同的id注册同一个类型, 按类型查找时返回第一个
+	assert.Nil(t, TryRegisterMsgMeta(New(2000, reflect.TypeOf(Ping{}))))
+	assert.Nil(t, TryRegisterMsgMeta(New(2001, reflect.TypeOf(&Ping{}))))
+	m, err := GetMsgMetaByType(reflect.TypeOf(Ping{}))
+	assert.Nil(t, err, err)
+	assert.Equal(t, uint32(2000), m.Identify())
+	_, err = GetMsgMeta(2001)
+	assert.Nil(t, err, err)
+
+	SetStrictTypes(true)
+	defer SetStrictTypes(false)
+	assert.Nil(t, TryRegisterMsgMeta(New(2002, reflect.TypeOf(Pong{}))))
+	assert.NotNil(t, TryRegisterMsgMeta(New(2003, reflect.TypeOf(&Pong{}))))
+	assert.Panics(t, func() { RegisterMsgMeta(New(2004, reflect.TypeOf(Pong{}))) })
+}
+
+func TestRegisterMsgType(t *testing.T) {
+	type Chat struct{ Text string }
+
+	m, err := TryRegisterMsgType(reflect.TypeOf(&Chat{}))
+	if !assert.Nil(t, err, err) {
+		return
+	}
+	defer unregister(m.Identify())
+	assert.Equal(t, NameID("github.com/MaxnSter/gnet/meta.Chat"), m.Identify())
+
+	_, err = TryRegisterMsgType(reflect.TypeOf(Chat{}))
+	assert.NotNil(t, err)
+	_, err = TryRegisterMsgType(reflect.TypeOf(struct{}{}))
+	assert.NotNil(t, err)
+
+	found, err := GetMsgMetaByType(reflect.TypeOf(Chat{}))
+	assert.Nil(t, err, err)
+	assert.Equal(t, m.Identify(), found.Identify())
+}
@@ -0,0 +1,66 @@
+package gnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/MaxnSter/gnet/timer"
+	"github.com/pkg/errors"
+)
+
+// ModuleConfig 以注册的组件名称描述一个Module, 见BuildModule.
+// 组件所在的plugin包需要被import, 以完成注册. 配置文件见config包
+type ModuleConfig struct {
+	// Pool pool的名称, 例如poolRaceOther
+	Pool string
+
+	// PoolConfig 创建pool使用的配置, 见pool.Config
+	PoolConfig pool.Config
+
+	// Coder coder的名称, 例如json
+	Coder string
+
+	// Packer packer的名称, 例如lv
+	Packer string
+
+	// Timer timer的名称, 例如timer_heap, 为空时module不设置timer
+	Timer string
+}
+
+// BuildModule 根据c从各组件的注册表中创建Module, opts与NewModule相同.
+// 所有无法找到的组件一起通过错误返回, 此时不会创建任何组件
+func BuildModule(c ModuleConfig, opts ...func(Module)) (Module, error) {
+	var problems []string
+	check := func(kind, name string, registered []string) {
+		for _, r := range registered {
+			if r == name {
+				return
+			}
+		}
+		problems = append(problems, fmt.Sprintf("unknown %s %q, registered: [%s]",
+			kind, name, strings.Join(registered, " ")))
+	}
+
+	// pool与timer每次获取都会创建新的实例, 先检查所有名称, 避免创建之后无法Stop
+	check("pool", c.Pool, pool.ListWorkerPools())
+	check("coder", c.Coder, codec.ListCoders())
+	check("packer", c.Packer, packer.ListPackers())
+	if c.Timer != "" {
+		check("timer", c.Timer, timer.ListTimers())
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.Errorf("invalid module config: %s", strings.Join(problems, "; "))
+	}
+
+	if c.Timer != "" {
+		opts = append([]func(Module){WithTimer(timer.MustGetTimer(c.Timer))}, opts...)
+	}
+	p := pool.MustGetWorkerPool(c.Pool, func(pc *pool.Config) {
+		*pc = c.PoolConfig
+	})
+	return NewModule(p, codec.MustGetCoder(c.Coder), packer.MustGetPacker(c.Packer), opts...), nil
+}
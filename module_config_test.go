@@ -0,0 +1,67 @@
+package gnet
+
+import (
+	"testing"
+
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/MaxnSter/gnet/timer/plugins/timer_heap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildModule(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		config   ModuleConfig
+		pool     pool.Config
+		timer    string
+		problems []string
+	}{
+		{
+			name: "valid",
+			config: ModuleConfig{Pool: "poolRaceOther", PoolConfig: pool.Config{QueueSize: 16},
+				Coder: "json", Packer: "lv", Timer: timer_heap.Name},
+			pool:  pool.Config{QueueSize: 16},
+			timer: timer_heap.Name,
+		},
+		{
+			name:   "empty timer",
+			config: ModuleConfig{Pool: "poolRaceOther", Coder: "json", Packer: "lv"},
+			pool:   pool.Config{QueueSize: 1024},
+		},
+		{
+			name:   "unknown",
+			config: ModuleConfig{Pool: "p?", Coder: "c?", Packer: "k?", Timer: "t?"},
+			problems: []string{`unknown pool "p?"`, `unknown coder "c?"`,
+				`unknown packer "k?"`, `unknown timer "t?"`},
+		},
+		{
+			name:     "unknown coder only",
+			config:   ModuleConfig{Pool: "poolRaceOther", Coder: "c?", Packer: "lv"},
+			problems: []string{`unknown coder "c?"`},
+		},
+	} {
+		m, err := BuildModule(c.config)
+		if len(c.problems) > 0 {
+			assert.Nil(t, m, c.name)
+			if assert.NotNil(t, err, c.name) {
+				for _, p := range c.problems {
+					assert.Contains(t, err.Error(), p, c.name)
+				}
+			}
+			continue
+		}
+
+		if !assert.Nil(t, err, c.name) {
+			continue
+		}
+		assert.Equal(t, "poolRaceOther", m.Pool().String(), c.name)
+		assert.Equal(t, c.pool, m.Pool().(pool.ConfigReporter).Config(), c.name)
+		assert.Equal(t, "json", m.Coder().String(), c.name)
+		assert.Equal(t, "lv", m.Packer().String(), c.name)
+		if c.timer == "" {
+			assert.Nil(t, m.Timer(), c.name)
+		} else if assert.NotNil(t, m.Timer(), c.name) {
+			assert.Equal(t, c.timer, m.Timer().String(), c.name)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gnet
 
 import (
+	"github.com/MaxnSter/gnet/clock"
 	"github.com/MaxnSter/gnet/codec"
 	"github.com/MaxnSter/gnet/packer"
 	"github.com/MaxnSter/gnet/pool"
@@ -17,6 +18,12 @@ type Module interface {
 	Pool() pool.Pool
 	Coder() codec.Coder
 	Packer() packer.Packer
+
+	// Timer返回module的定时器, 未设置时为nil, 见WithTimer
+	Timer() timer.Timer
+
+	// Clock返回module使用的时间, 默认为clock.Real, 见WithClock
+	Clock() clock.Clock
 }
 
 type moduleWrapper struct {
@@ -25,6 +32,7 @@ type moduleWrapper struct {
 	packer packer.Packer
 
 	timer timer.Timer
+	clock clock.Clock // 未设置时为nil
 }
 
 func (m *moduleWrapper) Pool() pool.Pool {
@@ -39,10 +47,63 @@ func (m *moduleWrapper) Packer() packer.Packer {
 	return m.packer
 }
 
-func NewModule(pool pool.Pool, c codec.Coder, packer packer.Packer) Module {
-	return &moduleWrapper{
+func (m *moduleWrapper) Timer() timer.Timer {
+	return m.timer
+}
+
+func (m *moduleWrapper) Clock() clock.Clock {
+	return clock.OrReal(m.clock)
+}
+
+func NewModule(pool pool.Pool, c codec.Coder, packer packer.Packer, opts ...func(Module)) Module {
+	m := &moduleWrapper{
 		pool:   pool,
 		coder:  c,
 		packer: packer,
 	}
+
+	for _, f := range opts {
+		f(m)
+	}
+	return m
+}
+
+// WithTimer 设置module的定时器, server与client运行时随pool一起启动与关闭,
+// 定时器的callback投放到module的pool中执行
+func WithTimer(t timer.Timer) func(Module) {
+	return func(m Module) {
+		m.(*moduleWrapper).timer = t
+	}
+}
+
+// WithClock 设置module使用的时间, session的AfterFunc与Every据此计算到期时间,
+// module的timer实现了timer.ClockSetter时, 运行前同样改为使用该clock.
+// net.Conn的deadline始终使用真实时间
+func WithClock(c clock.Clock) func(Module) {
+	return func(m Module) {
+		m.(*moduleWrapper).clock = c
+	}
+}
+
+// runModule 启动module的pool与timer
+func runModule(m Module) {
+	m.Pool().Run()
+	if t := m.Timer(); t != nil {
+		if cs, ok := t.(timer.ClockSetter); ok {
+			if mw, ok := m.(*moduleWrapper); ok && mw.clock != nil {
+				cs.SetClock(mw.clock)
+			}
+		}
+		t.SetPool(m.Pool())
+		t.Run()
+	}
+}
+
+// stopModule 关闭module的timer与pool, timer先于pool关闭,
+// 保证pool关闭后不再有callback投放进来
+func stopModule(m Module) {
+	if t := m.Timer(); t != nil {
+		t.Stop()
+	}
+	m.Pool().Stop()
 }
@@ -0,0 +1,277 @@
+package gnet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/pkg/errors"
+)
+
+const (
+	negotiationVersion = "GNET/1"
+	negotiationMaxLine = 1 << 10
+
+	// SubprotocolPrefix websocket子协议的前缀, 子协议格式为gnet.<coder>.<packer>,
+	// 例如gnet.json.lv, packer可以省略
+	SubprotocolPrefix = "gnet."
+)
+
+// Negotiation 是session建立时协商coder与packer的配置, 见WithNegotiation.
+//
+// tcp连接上, 在OnSession之前,client发送一行:
+//
+//	GNET/1 coder=json,msgpack packer=lv,tlv\n
+//
+// server从中选出双方都支持的coder与packer并回复:
+//
+//	GNET/1 coder=json packer=lv\n
+//
+// 或者在无法协商时回复错误后关闭连接:
+//
+//	GNET/1 error=no common coder\n
+//
+// websocket连接上, 若已经通过子协议(见SubprotocolPrefix)选定,则不再发送上述握手,
+// 子协议中的coder与packer同样需要被Coders与Packers接受
+type Negotiation struct {
+	// Coders 可接受的coder名称,按优先级排列
+	// client为空时不提出要求,由server使用module的coder
+	// server为空时只接受module的coder, 见AcceptRegistered
+	Coders []string
+
+	// Packers 可接受的packer名称,规则与Coders相同
+	Packers []string
+
+	// AcceptRegistered 为true时, server的Coders或Packers为空时接受注册的任意coder或packer.
+	// 这允许client选择比module更弱的组件(例如以lv代替packer_crypto), 需要显式开启
+	AcceptRegistered bool
+}
+
+// WithNegotiation 开启coder与packer的协商,server与client必须同时开启
+func WithNegotiation(n Negotiation) func(Operator) {
+	return func(operator Operator) {
+		operator.(*operatorWrapper).negotiation = &n
+	}
+}
+
+// subprotocol 由支持websocket子协议的连接实现
+type subprotocol interface {
+	Subprotocol() string
+}
+
+// negotiate 与对端协商本session使用的coder与packer
+func (s *session) negotiate() error {
+	o, ok := s.operator.(*operatorWrapper)
+	if !ok || o.negotiation == nil {
+		return nil
+	}
+	n := o.negotiation
+
+	if sp, ok := s.raw.(subprotocol); ok && strings.HasPrefix(sp.Subprotocol(), SubprotocolPrefix) {
+		return s.negotiateSubprotocol(n, strings.TrimPrefix(sp.Subprotocol(), SubprotocolPrefix))
+	}
+
+	// 对端始终不发送协商数据时, 不能一直占用session
+	s.raw.SetDeadline(time.Now().Add(s.handshakeTimeout))
+	defer s.raw.SetDeadline(time.Time{})
+
+	if s.client {
+		return s.negotiateClient(n)
+	}
+	return s.negotiateServer(n)
+}
+
+// negotiateSubprotocol 使用websocket子协议中的coder与packer, 同样受Negotiation的限制
+func (s *session) negotiateSubprotocol(n *Negotiation, sub string) error {
+	parts := strings.SplitN(sub, ".", 2)
+	coder, p := parts[0], ""
+	if len(parts) == 2 {
+		p = parts[1]
+	}
+
+	coder, err := choose(n.Coders, coder, s.coderAllowed(n))
+	if err != nil {
+		return errors.Wrapf(err, "subprotocol %s%s", SubprotocolPrefix, sub)
+	}
+	p, err = choose(n.Packers, p, s.packerAllowed(n))
+	if err != nil {
+		return errors.Wrapf(err, "subprotocol %s%s", SubprotocolPrefix, sub)
+	}
+	return s.apply(coder, p)
+}
+
+func (s *session) negotiateClient(n *Negotiation) error {
+	hello := fmt.Sprintf("%s coder=%s packer=%s\n", negotiationVersion,
+		strings.Join(n.Coders, ","), strings.Join(n.Packers, ","))
+	if _, err := io.WriteString(s.raw, hello); err != nil {
+		return errors.Wrap(err, "send negotiation failed")
+	}
+
+	fields, err := readNegotiation(s.rd)
+	if err != nil {
+		return err
+	}
+	if reason, ok := fields["error"]; ok {
+		return errors.Errorf("negotiation rejected: %s", reason)
+	}
+
+	coder, packer := fields["coder"], fields["packer"]
+	if !accept(n.Coders, coder) || !accept(n.Packers, packer) {
+		return errors.Errorf("negotiation mismatch, coder :%s, packer :%s", coder, packer)
+	}
+	return s.apply(coder, packer)
+}
+
+func (s *session) negotiateServer(n *Negotiation) error {
+	fields, err := readNegotiation(s.rd)
+	if err != nil {
+		return err
+	}
+
+	coder, cerr := choose(n.Coders, fields["coder"], s.coderAllowed(n))
+	p, perr := choose(n.Packers, fields["packer"], s.packerAllowed(n))
+
+	reply := fmt.Sprintf("%s coder=%s packer=%s\n", negotiationVersion, coder, p)
+	if cerr != nil || perr != nil {
+		reason := "no common coder"
+		if cerr == nil {
+			reason = "no common packer"
+		}
+		reply = fmt.Sprintf("%s error=%s\n", negotiationVersion, reason)
+	}
+
+	if _, err := io.WriteString(s.raw, reply); err != nil {
+		return errors.Wrap(err, "send negotiation failed")
+	}
+	if cerr != nil {
+		return cerr
+	}
+	if perr != nil {
+		return perr
+	}
+	return s.apply(coder, p)
+}
+
+// apply 将session的coder与packer替换为协商结果, 名称为空时保持module的默认值
+func (s *session) apply(coderName, packerName string) error {
+	if coderName != "" {
+		c, err := codec.GetCoder(coderName)
+		if err != nil {
+			return err
+		}
+		s.coder = codec.NewSession(c)
+	}
+
+	if packerName != "" {
+		p, err := packer.GetPacker(packerName)
+		if err != nil {
+			return err
+		}
+		s.packer = packer.NewSession(p)
+	}
+
+	return nil
+}
+
+// readNegotiation 读取一行协商数据,解析为key=value
+func readNegotiation(rd *bufio.Reader) (map[string]string, error) {
+	var line []byte
+	for {
+		frag, err := rd.ReadSlice('\n')
+		line = append(line, frag...)
+		if len(line) > negotiationMaxLine {
+			return nil, errors.Errorf("negotiation line too long, max:%d", negotiationMaxLine)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "receive negotiation failed")
+		}
+		break
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 || fields[0] != negotiationVersion {
+		return nil, errors.Errorf("bad negotiation: %q", line)
+	}
+
+	kv := map[string]string{}
+	for _, f := range fields[1:] {
+		if i := strings.IndexByte(f, '='); i > 0 {
+			kv[f[:i]] = f[i+1:]
+		}
+	}
+	return kv, nil
+}
+
+// choose 按本端的优先级,从对端提供的列表中选出一个名称.
+// 对端未提出要求时返回空,使用module的默认值. 本端列表为空时, 选择对端第一个被allowed接受的名称
+func choose(local []string, remote string, allowed func(string) error) (string, error) {
+	if remote == "" {
+		return "", nil
+	}
+
+	offered := strings.Split(remote, ",")
+	if len(local) == 0 {
+		for _, name := range offered {
+			if allowed(name) == nil {
+				return name, nil
+			}
+		}
+		return "", errors.Errorf("none of %q allowed", remote)
+	}
+
+	for _, name := range local {
+		if accept(offered, name) {
+			return name, nil
+		}
+	}
+	return "", errors.Errorf("no common choice, local :%v, remote :%s", local, remote)
+}
+
+// coderAllowed 返回Coders为空时server接受的coder: 默认只有module的coder, 见AcceptRegistered
+func (s *session) coderAllowed(n *Negotiation) func(string) error {
+	return func(name string) error {
+		c, err := codec.GetCoder(name)
+		if err != nil || n.AcceptRegistered {
+			return err
+		}
+		if c.String() != s.coder.String() {
+			return errors.Errorf("coder %s is not the module coder %s", name, s.coder)
+		}
+		return nil
+	}
+}
+
+// packerAllowed 与coderAllowed相同, 用于packer
+func (s *session) packerAllowed(n *Negotiation) func(string) error {
+	return func(name string) error {
+		p, err := packer.GetPacker(name)
+		if err != nil || n.AcceptRegistered {
+			return err
+		}
+		if p.String() != s.packer.String() {
+			return errors.Errorf("packer %s is not the module packer %s", name, s.packer)
+		}
+		return nil
+	}
+}
+
+// accept 判断name是否在list中, 空的name表示使用默认值,总是接受
+func accept(list []string, name string) bool {
+	if name == "" {
+		return true
+	}
+
+	for _, l := range list {
+		if l == name {
+			return true
+		}
+	}
+	return false
+}
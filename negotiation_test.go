@@ -0,0 +1,127 @@
+package gnet
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet/codec"
+	_ "github.com/MaxnSter/gnet/codec/plugins/codec_json"
+	_ "github.com/MaxnSter/gnet/codec/plugins/codec_msgpack"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	_ "github.com/MaxnSter/gnet/packer/plugins/packer_type_length_value"
+	"github.com/MaxnSter/gnet/pool/plugins/pool_race_other"
+	"github.com/stretchr/testify/assert"
+)
+
+type subprotocolConn struct {
+	net.Conn
+	sub string
+}
+
+func (c subprotocolConn) Subprotocol() string {
+	return c.sub
+}
+
+func newTestSession(conn net.Conn, cb Callback, opts ...func(Operator)) *session {
+	m := NewModule(pool_race_other.New(), codec.MustGetCoder("json"), packer_length_value.New())
+	return newSession(1, conn, nil, m, NewOperator(m, cb, opts...), false).(*session)
+}
+
+func TestNegotiation_Subprotocol(t *testing.T) {
+	n := WithNegotiation(Negotiation{Coders: []string{"json"}, Packers: []string{"lv"}})
+
+	for _, c := range []struct {
+		sub           string
+		coder, packer string
+		ok            bool
+	}{
+		{sub: "gnet.json.lv", coder: "json", packer: "lv", ok: true},
+		{sub: "gnet.json", coder: "json", packer: "lv", ok: true},
+		// 已注册但不在Negotiation中
+		{sub: "gnet.msgpack.lv"},
+		{sub: "gnet.json.tlv"},
+		{sub: "gnet.unknown"},
+	} {
+		a, b := net.Pipe()
+		s := newTestSession(subprotocolConn{Conn: a, sub: c.sub}, Callback{}, n)
+
+		err := s.negotiate()
+		if c.ok {
+			assert.NoError(t, err, c.sub)
+			assert.Equal(t, c.coder, s.coder.String(), c.sub)
+			assert.Equal(t, c.packer, s.packer.String(), c.sub)
+		} else {
+			assert.Error(t, err, c.sub)
+		}
+		a.Close()
+		b.Close()
+	}
+}
+
+func TestNegotiation_EmptyList(t *testing.T) {
+	for _, c := range []struct {
+		sub string
+		n   Negotiation
+		ok  bool
+	}{
+		// server的列表为空时只接受module的coder与packer
+		{sub: "gnet.json.lv", ok: true},
+		{sub: "gnet.msgpack.lv"},
+		{sub: "gnet.json.tlv"},
+		{sub: "gnet.msgpack.tlv", n: Negotiation{AcceptRegistered: true}, ok: true},
+		{sub: "gnet.unknown", n: Negotiation{AcceptRegistered: true}},
+	} {
+		a, b := net.Pipe()
+		s := newTestSession(subprotocolConn{Conn: a, sub: c.sub}, Callback{}, WithNegotiation(c.n))
+
+		err := s.negotiate()
+		if c.ok {
+			assert.NoError(t, err, c.sub)
+		} else {
+			assert.Error(t, err, c.sub)
+		}
+		a.Close()
+		b.Close()
+	}
+
+	// tcp协商同样受限, server回复错误
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	s := newTestSession(a, Callback{}, WithNegotiation(Negotiation{}))
+	done := make(chan error, 1)
+	go func() {
+		done <- s.negotiate()
+	}()
+
+	_, err := b.Write([]byte("GNET/1 coder=msgpack packer=lv\n"))
+	assert.NoError(t, err)
+	reply, err := bufio.NewReader(b).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "GNET/1 error=no common coder\n", reply)
+	assert.Error(t, <-done)
+}
+
+func TestNegotiation_Timeout(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	s := newTestSession(a, Callback{},
+		WithNegotiation(Negotiation{}), WithTimeouts(Timeouts{Handshake: 50 * time.Millisecond}))
+
+	// 对端连接后不发送协商数据
+	done := make(chan error, 1)
+	go func() {
+		done <- s.negotiate()
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("negotiation not timed out")
+	}
+}
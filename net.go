@@ -2,6 +2,11 @@ package gnet
 
 import (
 	"net"
+	"time"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/timer"
 )
 
 type SessionManager interface {
@@ -21,6 +26,21 @@ type NetSession interface {
 	Send(message interface{})
 	AccessManager() SessionManager
 
+	// Packer返回该session使用的packer, 开启协商时为协商的结果
+	// 若packer实现了packer.SessionPacker,则每个session持有独立的实例
+	Packer() packer.Packer
+
+	// Coder返回该session使用的coder, 未开启协商时即为module的coder
+	// 若coder实现了codec.SessionCoder,则每个session持有独立的实例
+	Coder() codec.Coder
+
+	// AfterFunc在d之后执行一次f, Every每隔d执行一次f, session关闭时自动取消.
+	// f以该session投放到module的pool中, 使用poolRaceSelf与poolRaceOther时与OnMessage串行执行,
+	// 使用poolNoRace时可能与OnMessage并发执行.
+	// 需要module设置timer(见WithTimer), 未设置时记录错误, 返回的Cancel不做任何事, f不会执行
+	AfterFunc(d time.Duration, f timer.OnTimeOut) timer.Cancel
+	Every(d time.Duration, f timer.OnTimeOut) timer.Cancel
+
 	Runner
 }
 
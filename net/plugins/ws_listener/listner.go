@@ -1,22 +1,28 @@
 package ws_listener
 
 import (
+	"errors"
 	"github.com/gorilla/websocket"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// ErrClosed 是listener关闭之后Accept返回的错误
+var ErrClosed = errors.New("ws listener closed")
+
+// listener 拥有独立的http.ServeMux与http.Server, 同一进程中可以创建多个listener
 type listener struct {
-	conns chan conn
+	conns chan net.Conn
 	w     websocket.Upgrader
+	srv   *http.Server
 
-	net.Listener
-}
+	once sync.Once
+	done chan struct{}
+	err  error // done关闭的原因, 由Accept返回
 
-type conn struct {
-	c   net.Conn
-	err error
+	net.Listener
 }
 
 type wsConn struct {
@@ -42,6 +48,11 @@ func (w *wsConn) Write(b []byte) (n int, err error) {
 	return writer.Write(b)
 }
 
+// Subprotocol 返回握手时协商的websocket子协议, gnet据此选择session的coder与packer
+func (w *wsConn) Subprotocol() string {
+	return w.raw.Subprotocol()
+}
+
 func (w *wsConn) Close() error {
 	return w.raw.Close()
 }
@@ -71,49 +82,81 @@ func (w *wsConn) SetWriteDeadline(t time.Time) error {
 	return w.raw.SetWriteDeadline(t)
 }
 
+// New 与Listen相同, 出错时panic
 func New(addr, url string, w websocket.Upgrader) net.Listener {
-	l, err := net.Listen("tcp", addr)
+	l, err := Listen(addr, url, w)
 	if err != nil {
 		panic(err)
 	}
+	return l
+}
+
+// Listen 在addr上监听, 将url的http请求升级为websocket连接
+func Listen(addr, url string, w websocket.Upgrader) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
 
 	wsListener := &listener{
 		w:        w,
 		Listener: l,
-		conns:    make(chan conn),
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
 	}
-	http.Handle(url, wsListener.wsHandler())
-	return wsListener
+
+	mux := http.NewServeMux()
+	mux.Handle(url, wsListener.wsHandler())
+	wsListener.srv = &http.Server{Handler: mux}
+
+	go func() {
+		wsListener.shutdown(wsListener.srv.Serve(l))
+	}()
+	return wsListener, nil
 }
 
 func (l *listener) wsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// 升级失败时Upgrader已经向对端回复了错误, 不影响后续的连接
 		raw, err := l.w.Upgrade(w, r, nil)
-		l.conns <- conn{
-			c: &wsConn{
-				raw: raw,
-
-				// todo
-				messageType: websocket.TextMessage,
-			},
-			err: err,
+		if err != nil {
+			return
+		}
+
+		c := &wsConn{
+			raw: raw,
+
+			// todo
+			messageType: websocket.TextMessage,
+		}
+		select {
+		case l.conns <- c:
+		case <-l.done:
+			c.Close()
 		}
 	}
 }
 
-func (l *listener) Accept() (net.Conn, error) {
-	go func() {
-		if err := http.Serve(l.Listener, nil); err != nil {
-			panic(err)
-		}
-	}()
+// shutdown 以err结束Accept, 只有第一次调用生效
+func (l *listener) shutdown(err error) {
+	l.once.Do(func() {
+		l.err = err
+		close(l.done)
+	})
+}
 
-	c := <-l.conns
-	return c.c, c.err
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, l.err
+	}
 }
 
 func (l *listener) Close() error {
-	return l.Listener.Close()
+	l.shutdown(ErrClosed)
+	return l.srv.Close()
 }
 
 func (l *listener) Addr() net.Addr {
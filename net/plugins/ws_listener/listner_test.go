@@ -0,0 +1,46 @@
+package ws_listener
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListener(t *testing.T) {
+	// 同一路径的两个listener互不影响
+	l1, err := Listen("127.0.0.1:0", "/ws", websocket.Upgrader{})
+	assert.Nil(t, err)
+	l2, err := Listen("127.0.0.1:0", "/ws", websocket.Upgrader{})
+	assert.Nil(t, err)
+	defer l2.Close()
+
+	// 非websocket请求被拒绝, 不影响Accept
+	resp, err := http.Get("http://" + l1.Addr().String() + "/ws")
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	client, _, err := websocket.DefaultDialer.Dial("ws://"+l1.Addr().String()+"/ws", nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer client.Close()
+
+	c, err := l1.Accept()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte("ping")))
+	b := make([]byte, 16)
+	n, err := c.Read(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "ping", string(b[:n]))
+	c.Close()
+
+	assert.Nil(t, l1.Close())
+	_, err = l1.Accept()
+	assert.Equal(t, ErrClosed, err)
+}
@@ -1,10 +1,15 @@
 package gnet
 
 import (
+	"fmt"
+	"github.com/MaxnSter/gnet/codec"
 	"github.com/MaxnSter/gnet/meta"
-	"github.com/MaxnSter/gnet/packer/plugins/packer_type_length_value"
+	"github.com/MaxnSter/gnet/packer"
 	"github.com/MaxnSter/gnet/pool"
+	"github.com/pkg/errors"
 	"io"
+	"reflect"
+	"time"
 )
 
 type Callback struct {
@@ -24,10 +29,62 @@ type WriteInterceptor struct {
 	InWrite  func(w io.Writer, buf []byte) (io.Writer, []byte)
 }
 
+// UnknownMsgPolicy 决定收到未注册msgId的消息时operator的行为
+type UnknownMsgPolicy int
+
+const (
+	// UnknownMsgClose 返回*UnknownMsgError,session随之关闭
+	UnknownMsgClose UnknownMsgPolicy = iota
+	// UnknownMsgDrop 丢弃该消息,继续读取下一条
+	UnknownMsgDrop
+	// UnknownMsgRaw 不解码,以*UnknownMsg作为消息投递给OnMessage
+	UnknownMsgRaw
+)
+
+// UnknownMsg 是UnknownMsgRaw策略下投递给OnMessage的消息
+type UnknownMsg struct {
+	ID     uint32
+	Header packer.Header
+	Data   []byte
+}
+
+// MsgError 表示单条消息在写入连接之前(查找msgId,编码)出错,
+// 与连接本身无关,session丢弃该消息后继续工作.
+// 使用codec.StreamCoder时编码之后的错误不是MsgError, session随之关闭
+type MsgError struct {
+	Msg interface{}
+	Err error
+}
+
+func (e *MsgError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *MsgError) Cause() error {
+	return e.Err
+}
+
+// UnknownMsgError 是UnknownMsgClose策略下Read返回的错误
+type UnknownMsgError struct {
+	ID uint32
+}
+
+func (e *UnknownMsgError) Error() string {
+	return fmt.Sprintf("unknown message, id :%d", e.ID)
+}
+
+// ProtocolError 标识该错误为对端违反协议, 见packer.IsProtocolError
+func (e *UnknownMsgError) ProtocolError() bool {
+	return true
+}
+
 type Operator interface {
 	PostEvent(ev Event)
-	Read(reader io.Reader) (interface{}, error)
-	Write(writer io.Writer, msg interface{}) error
+	// Read从reader中读取并解析一条消息,使用session对应的packer解包
+	// 若packer带有消息类型头(见packer.HeaderCodec),同时返回解析出的头
+	Read(session NetSession, reader io.Reader) (interface{}, packer.Header, error)
+	// Write将msg编码,使用session对应的packer封包后写入writer
+	Write(session NetSession, writer io.Writer, msg interface{}) error
 
 	GetCallback() Callback
 }
@@ -38,7 +95,22 @@ type operatorWrapper struct {
 	ReadInterceptor
 	WriteInterceptor
 
-	meta meta.Meta
+	meta          meta.Meta
+	unknownPolicy UnknownMsgPolicy
+	negotiation   *Negotiation
+	timeouts      Timeouts
+}
+
+// Timeouts 是session的超时配置, 为0的字段使用默认值, 见WithTimeouts
+type Timeouts struct {
+	// Handshake 协商与packer握手的超时时间, 默认10s
+	Handshake time.Duration
+
+	// Idle 连续多久没有读到任何消息时关闭session, 默认不限制
+	Idle time.Duration
+
+	// StopGrace session关闭时, 等待未完成的读写的时间, 默认3s
+	StopGrace time.Duration
 }
 
 func NewOperator(m Module, cb Callback, opts ...func(Operator)) Operator {
@@ -72,6 +144,20 @@ func WithMeta(m meta.Meta) func(Operator) {
 	}
 }
 
+// WithUnknownMsgPolicy 指定收到未注册msgId的消息时的行为,默认为UnknownMsgClose
+func WithUnknownMsgPolicy(p UnknownMsgPolicy) func(Operator) {
+	return func(operator Operator) {
+		operator.(*operatorWrapper).unknownPolicy = p
+	}
+}
+
+// WithTimeouts 设置session的超时时间
+func WithTimeouts(t Timeouts) func(Operator) {
+	return func(operator Operator) {
+		operator.(*operatorWrapper).timeouts = t
+	}
+}
+
 func (s *operatorWrapper) GetCallback() Callback {
 	return s.Callback
 }
@@ -84,57 +170,198 @@ func (s *operatorWrapper) PostEvent(ev Event) {
 	}
 }
 
-func (s *operatorWrapper) Read(reader io.Reader) (interface{}, error) {
+func (s *operatorWrapper) Read(session NetSession, reader io.Reader) (interface{}, packer.Header, error) {
+	for {
+		msg, h, dropped, err := s.read(session, reader)
+		if err != nil || !dropped {
+			return msg, h, err
+		}
+	}
+}
+
+// read读取一条消息,若该消息因UnknownMsgDrop策略被丢弃,dropped为true
+func (s *operatorWrapper) read(session NetSession, reader io.Reader) (msg interface{}, h packer.Header,
+	dropped bool, err error) {
 	r, m := reader, s.meta
 	if s.PreRead != nil {
 		r, m = s.PreRead(r, m)
 	}
 
-	buf, err := s.Packer().Unpack(reader)
+	p := session.Packer()
+	buf, err := p.Unpack(r)
 	if err != nil {
-		return nil, err
+		return
 	}
-	if s.Packer().String() == packer_type_length_value.Name {
-		var msgId uint32
-		msgId, buf = packer_type_length_value.UnpackMsgId(buf)
+	if hc, ok := packer.HeaderCodecOf(p); ok {
+		if h, buf, err = hc.UnpackHeader(buf); err != nil {
+			return
+		}
+
+		msgId := msgIdOfHeader(h)
+		if m, err = meta.GetMsgMeta(msgId); err != nil {
+			if s.unknownPolicy == UnknownMsgDrop || s.unknownPolicy == UnknownMsgRaw {
+				if err = s.skip(session, msgId, buf); err != nil {
+					return nil, h, false, err
+				}
+			}
 
-		m = meta.MustGetMsgMeta(msgId)
+			switch s.unknownPolicy {
+			case UnknownMsgDrop:
+				return nil, h, true, nil
+			case UnknownMsgRaw:
+				return &UnknownMsg{ID: msgId, Header: h, Data: buf}, h, false, nil
+			default:
+				return nil, h, false, &UnknownMsgError{ID: msgId}
+			}
+		}
 	}
 	if s.InRead != nil {
 		buf, m = s.InRead(buf, m)
 	}
 
-	var msg interface{}
 	if m != nil {
 		msg = m.New()
 	}
-	if err = s.Coder().Decode(buf, msg); err != nil {
-		return nil, err
+	if err = s.coderOf(session, m).Decode(buf, msg); err != nil {
+		return nil, h, false, err
 	}
 	if s.PostRead != nil {
 		msg = s.PostRead(msg)
 	}
 
-	return msg, nil
+	return msg, h, false, nil
 }
 
-func (s *operatorWrapper) Write(writer io.Writer, msg interface{}) error {
+func (s *operatorWrapper) Write(session NetSession, writer io.Writer, msg interface{}) error {
 	if s.PreWrite != nil {
 		writer, msg = s.PreWrite(writer, msg)
 	}
 
-	buf, err := s.Coder().Encode(msg)
+	var (
+		h       packer.Header
+		payload = msg
+		p       = session.Packer()
+		err     error
+	)
+	hc, hasHeader := packer.HeaderCodecOf(p)
+	if env, ok := envelopeOf(msg); ok {
+		payload = env.Payload
+		h = packer.Header{ID: env.ID, Topic: env.Topic, Flags: env.Flags, Seq: env.Seq}
+	} else if hasHeader {
+		if h.ID, err = msgIdOf(msg); err != nil {
+			return &MsgError{Msg: msg, Err: err}
+		}
+	}
+	if hasHeader && h.Topic == "" {
+		h.Topic, _ = meta.GetMsgName(h.ID)
+	}
+
+	var c codec.Coder
+	if hasHeader {
+		c = meta.GetCoder(msgIdOfHeader(h))
+	} else if m, ok := metaOf(payload); ok {
+		c = meta.CoderOf(m)
+	}
+	if c == nil {
+		c = session.Coder()
+	} else {
+		c = sessionCoderOf(session, c)
+	}
+
+	// StreamCoder编码之后, 即使消息没有发送, 两端的状态也已经不一致, 只能关闭session
+	msgErr := func(err error) error {
+		if codec.IsStream(c) {
+			return errors.Wrapf(err, "stream coder %s out of sync", c)
+		}
+		return &MsgError{Msg: msg, Err: err}
+	}
+
+	buf, err := c.Encode(payload)
 	if err != nil {
-		return err
+		return msgErr(errors.Wrapf(err, "encode %T failed", payload))
 	}
 
 	if s.InWrite != nil {
 		writer, buf = s.InWrite(writer, buf)
 	}
 
-	if s.Packer().String() == packer_type_length_value.Name {
-		msgId := msg.(meta.Meta).Identify()
-		buf = packer_type_length_value.PackMsgId(msgId, buf)
+	if hasHeader {
+		if buf, err = hc.PackHeader(h, buf); err != nil {
+			return msgErr(err)
+		}
+	}
+	return p.Pack(writer, buf)
+}
+
+// skip 处理不会被解码的未知消息, 消息的coder为codec.StreamCoder时仍然需要解码以保持状态同步
+func (s *operatorWrapper) skip(session NetSession, msgId uint32, buf []byte) error {
+	c := meta.GetCoder(msgId)
+	if c == nil {
+		c = session.Coder()
+	} else {
+		c = sessionCoderOf(session, c)
+	}
+
+	if !codec.IsStream(c) {
+		return nil
+	}
+	return c.Decode(buf, nil)
+}
+
+// coderOf 返回m绑定的coder,未绑定时返回session的coder
+func (s *operatorWrapper) coderOf(session NetSession, m meta.Meta) codec.Coder {
+	if m != nil {
+		if c := meta.CoderOf(m); c != nil {
+			return sessionCoderOf(session, c)
+		}
+	}
+	return session.Coder()
+}
+
+// sessionCoderOf 返回原型c在session中独享的实例, session不支持时返回c本身
+func sessionCoderOf(session NetSession, c codec.Coder) codec.Coder {
+	if s, ok := session.(interface{ sessionCoder(codec.Coder) codec.Coder }); ok {
+		return s.sessionCoder(c)
+	}
+	return c
+}
+
+func envelopeOf(msg interface{}) (*meta.Envelope, bool) {
+	switch env := msg.(type) {
+	case meta.Envelope:
+		return &env, true
+	case *meta.Envelope:
+		return env, env != nil
+	default:
+		return nil, false
+	}
+}
+
+// msgIdOfHeader 返回消息头对应的msgId, 带有topic时为topic的meta.NameID
+func msgIdOfHeader(h packer.Header) uint32 {
+	if h.Topic != "" {
+		return meta.NameID(h.Topic)
+	}
+	return h.ID
+}
+
+// metaOf 返回msg对应的meta, msg实现了meta.Meta时即为其本身,
+// 否则根据msg的类型在meta中查找
+func metaOf(msg interface{}) (meta.Meta, bool) {
+	if m, ok := msg.(meta.Meta); ok {
+		return m, true
+	}
+
+	m, err := meta.GetMsgMetaByType(reflect.TypeOf(msg))
+	return m, err == nil
+}
+
+// msgIdOf 返回msg对应的msgId, 见metaOf
+func msgIdOf(msg interface{}) (uint32, error) {
+	m, ok := metaOf(msg)
+	if !ok {
+		return 0, errors.Errorf("can not find msgId for %T: register its type in meta, "+
+			"implement meta.Meta or send it in a meta.Envelope", msg)
 	}
-	return s.Packer().Pack(writer, buf)
+	return m.Identify(), nil
 }
@@ -0,0 +1,68 @@
+package gnet
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/MaxnSter/gnet/codec"
+	_ "github.com/MaxnSter/gnet/codec/plugins/codec_gob"
+	"github.com/MaxnSter/gnet/meta"
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	"github.com/stretchr/testify/assert"
+)
+
+type streamMsg struct {
+	Text string
+}
+
+const streamMsgId = 41001
+
+func init() {
+	meta.RegisterMsgMeta(meta.New(streamMsgId, reflect.TypeOf(streamMsg{})))
+}
+
+// newStreamSession 返回使用gob session coder与2字节msgId的session
+func newStreamSession(coder string, opts ...func(Operator)) *session {
+	a, _ := net.Pipe()
+	s := newTestSession(a, Callback{}, opts...)
+	s.coder = codec.NewSession(codec.MustGetCoder(coder))
+	s.packer = packer.WithHeader(packer_length_value.New(), packer.Uint16Header)
+	return s
+}
+
+func TestOperator_WriteStreamCoder(t *testing.T) {
+	// msgId超出2字节, PackHeader失败
+	bad := meta.Envelope{ID: 1 << 20, Payload: &streamMsg{Text: "lost"}}
+
+	// 普通coder只丢弃该消息
+	s := newStreamSession("json")
+	err := s.operator.Write(s, &bytes.Buffer{}, bad)
+	assert.IsType(t, &MsgError{}, err)
+
+	// gob已经记录该类型信息为已发送, 必须关闭session
+	s = newStreamSession("gob")
+	err = s.operator.Write(s, &bytes.Buffer{}, bad)
+	if assert.Error(t, err) {
+		_, ok := err.(*MsgError)
+		assert.False(t, ok, err.Error())
+	}
+}
+
+func TestOperator_DropStreamCoder(t *testing.T) {
+	w := newStreamSession("gob")
+	r := newStreamSession("gob", WithUnknownMsgPolicy(UnknownMsgDrop))
+
+	// 第一帧携带类型信息但msgId未注册, 被读取端丢弃
+	buf := &bytes.Buffer{}
+	assert.Nil(t, w.operator.Write(w, buf, meta.Envelope{ID: 7, Payload: &streamMsg{Text: "dropped"}}))
+	assert.Nil(t, w.operator.Write(w, buf, &streamMsg{Text: "next"}))
+
+	msg, h, err := r.operator.Read(r, buf)
+	if assert.Nil(t, err, err) {
+		assert.Equal(t, uint32(streamMsgId), h.ID)
+		assert.Equal(t, "next", msg.(*streamMsg).Text)
+	}
+}
@@ -0,0 +1,80 @@
+package packer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ChecksumSize 校验值trailer的长度
+	ChecksumSize = 4
+)
+
+// Checksum 定义了帧的完整性校验算法
+type Checksum interface {
+	Sum32(data []byte) uint32
+	String() string
+}
+
+type crc32Checksum struct {
+	name  string
+	table *crc32.Table
+}
+
+func (c *crc32Checksum) Sum32(data []byte) uint32 {
+	return crc32.Checksum(data, c.table)
+}
+
+func (c *crc32Checksum) String() string {
+	return c.name
+}
+
+var (
+	// CRC32C 使用Castagnoli多项式,大部分平台上有硬件加速
+	CRC32C Checksum = &crc32Checksum{name: "crc32c", table: crc32.MakeTable(crc32.Castagnoli)}
+
+	// CRC32 使用IEEE多项式
+	CRC32 Checksum = &crc32Checksum{name: "crc32", table: crc32.IEEETable}
+)
+
+// ChecksumError 表示帧校验失败,通常意味着链路上发生了数据损坏
+type ChecksumError struct {
+	Packer   string
+	Checksum string
+	Expect   uint32
+	Actual   uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: %s mismatch, expect:%#08x, actual:%#08x",
+		e.Packer, e.Checksum, e.Expect, e.Actual)
+}
+
+// ProtocolError 标识该错误为对端违反协议,session收到此类错误后应立即关闭
+func (e *ChecksumError) ProtocolError() bool {
+	return true
+}
+
+// PutChecksum 计算data的校验值,以大端序写入dst的前ChecksumSize个字节
+func PutChecksum(c Checksum, dst, data []byte) {
+	binary.BigEndian.PutUint32(dst, c.Sum32(data))
+}
+
+// VerifyChecksum 校验frame(data + checksum trailer),成功时返回去掉trailer的data,
+// 失败时返回*ChecksumError
+func VerifyChecksum(name string, c Checksum, frame []byte) ([]byte, error) {
+	if len(frame) < ChecksumSize {
+		return nil, errors.Errorf("%s: msg too short for %s, actual:%d", name, c, len(frame))
+	}
+
+	data, trailer := frame[:len(frame)-ChecksumSize], frame[len(frame)-ChecksumSize:]
+	expect, actual := binary.BigEndian.Uint32(trailer), c.Sum32(data)
+	if expect != actual {
+		return nil, &ChecksumError{Packer: name, Checksum: c.String(), Expect: expect, Actual: actual}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,33 @@
+package packer
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ProtocolError 表示对端发送的数据违反了packer的协议,
+// 例如帧长度非法,解压后超过上限等,session收到此类错误后应立即关闭
+type ProtocolError struct {
+	Packer string
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Packer, e.Reason)
+}
+
+func (e *ProtocolError) ProtocolError() bool {
+	return true
+}
+
+// NewProtocolError 返回一个*ProtocolError
+func NewProtocolError(packer string, format string, args ...interface{}) error {
+	return &ProtocolError{Packer: packer, Reason: fmt.Sprintf(format, args...)}
+}
+
+// IsProtocolError 判断err(或被errors.Wrap包装前的err)是否为协议错误
+func IsProtocolError(err error) bool {
+	pe, ok := errors.Cause(err).(interface{ ProtocolError() bool })
+	return ok && pe.ProtocolError()
+}
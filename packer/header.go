@@ -0,0 +1,181 @@
+package packer
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Header 是帧中携带的消息类型头.
+// 不同的HeaderCodec只使用其中的部分字段,例如Uint16Header只使用ID
+type Header struct {
+	// ID 消息的msgId
+	ID uint32
+
+	// Topic 基于名字的消息类型,不为空时msgId为meta.NameID(Topic)
+	Topic string
+
+	// Flags 由业务自行定义的标志位
+	Flags uint16
+
+	// Seq 由业务自行定义的序列号
+	Seq uint32
+}
+
+// HeaderCodec 负责在packer的body之前编解码消息类型头.
+// packer可以直接实现HeaderCodec(例如tlv),也可以通过WithHeader为任意packer添加
+type HeaderCodec interface {
+	PackHeader(h Header, value []byte) (body []byte, err error)
+	UnpackHeader(body []byte) (h Header, value []byte, err error)
+	String() string
+}
+
+// HeaderCodecOf 逐层解开Wrapper,返回第一个实现了HeaderCodec的packer
+func HeaderCodecOf(p Packer) (HeaderCodec, bool) {
+	for {
+		if hc, ok := p.(HeaderCodec); ok {
+			return hc, true
+		}
+
+		w, ok := p.(Wrapper)
+		if !ok {
+			return nil, false
+		}
+		p = w.Unwrap()
+	}
+}
+
+var (
+	// Uint32Header 4字节大端序msgId, tlv使用此格式
+	Uint32Header HeaderCodec = &fixedHeader{name: "id32", size: 4}
+
+	// Uint16Header 2字节大端序msgId
+	Uint16Header HeaderCodec = &fixedHeader{name: "id16", size: 2}
+
+	// SeqHeader 4字节msgId + 2字节flags + 4字节seq, 均为大端序
+	SeqHeader HeaderCodec = &fixedHeader{name: "seq", size: 10}
+
+	// TopicHeader 1字节长度 + topic, topic最长255字节
+	TopicHeader HeaderCodec = &topicHeader{}
+)
+
+type fixedHeader struct {
+	name string
+	size int
+}
+
+func (c *fixedHeader) PackHeader(h Header, value []byte) ([]byte, error) {
+	body := make([]byte, c.size+len(value))
+	switch c.size {
+	case 2:
+		if h.ID > math.MaxUint16 {
+			return nil, errors.Errorf("%s: msgId overflow, id :%d", c.name, h.ID)
+		}
+		binary.BigEndian.PutUint16(body, uint16(h.ID))
+	case 4:
+		binary.BigEndian.PutUint32(body, h.ID)
+	default:
+		binary.BigEndian.PutUint32(body, h.ID)
+		binary.BigEndian.PutUint16(body[4:], h.Flags)
+		binary.BigEndian.PutUint32(body[6:], h.Seq)
+	}
+
+	copy(body[c.size:], value)
+	return body, nil
+}
+
+func (c *fixedHeader) UnpackHeader(body []byte) (h Header, value []byte, err error) {
+	if len(body) < c.size {
+		return h, nil, NewProtocolError(c.name, "msg too short, min:%d, actual:%d", c.size, len(body))
+	}
+
+	switch c.size {
+	case 2:
+		h.ID = uint32(binary.BigEndian.Uint16(body))
+	case 4:
+		h.ID = binary.BigEndian.Uint32(body)
+	default:
+		h.ID = binary.BigEndian.Uint32(body)
+		h.Flags = binary.BigEndian.Uint16(body[4:])
+		h.Seq = binary.BigEndian.Uint32(body[6:])
+	}
+
+	return h, body[c.size:], nil
+}
+
+func (c *fixedHeader) String() string {
+	return c.name
+}
+
+type topicHeader struct{}
+
+func (c *topicHeader) PackHeader(h Header, value []byte) ([]byte, error) {
+	if len(h.Topic) == 0 || len(h.Topic) > math.MaxUint8 {
+		return nil, errors.Errorf("topic: bad topic length, topic :%q", h.Topic)
+	}
+
+	body := make([]byte, 1+len(h.Topic)+len(value))
+	body[0] = byte(len(h.Topic))
+	n := 1 + copy(body[1:], h.Topic)
+	copy(body[n:], value)
+	return body, nil
+}
+
+func (c *topicHeader) UnpackHeader(body []byte) (h Header, value []byte, err error) {
+	if len(body) < 1 || len(body) < 1+int(body[0]) {
+		return h, nil, NewProtocolError(c.String(), "msg too short, actual:%d", len(body))
+	}
+
+	n := 1 + int(body[0])
+	h.Topic = string(body[1:n])
+	return h, body[n:], nil
+}
+
+func (c *topicHeader) String() string {
+	return "topic"
+}
+
+// headerPacker 为任意packer添加消息类型头
+type headerPacker struct {
+	HeaderCodec
+	inner Packer
+}
+
+var (
+	_ Wrapper       = (*headerPacker)(nil)
+	_ SessionPacker = (*headerPacker)(nil)
+	_ Handshaker    = (*headerPacker)(nil)
+)
+
+// WithHeader 返回一个包装了p的packer,消息类型头由hc编解码.
+// 例如WithHeader(lv, Uint16Header)即为2字节msgId的tlv
+func WithHeader(p Packer, hc HeaderCodec) Packer {
+	return &headerPacker{HeaderCodec: hc, inner: p}
+}
+
+func (p *headerPacker) Unpack(r io.Reader) ([]byte, error) {
+	return p.inner.Unpack(r)
+}
+
+func (p *headerPacker) Pack(w io.Writer, body []byte) error {
+	return p.inner.Pack(w, body)
+}
+
+// String 返回packer的名称, 例如lv+id16
+func (p *headerPacker) String() string {
+	return p.inner.String() + "+" + p.HeaderCodec.String()
+}
+
+func (p *headerPacker) Unwrap() Packer {
+	return p.inner
+}
+
+func (p *headerPacker) NewSessionPacker() Packer {
+	return &headerPacker{HeaderCodec: p.HeaderCodec, inner: NewSession(p.inner)}
+}
+
+func (p *headerPacker) Handshake(r io.Reader, w io.Writer) error {
+	return Handshake(p.inner, r, w)
+}
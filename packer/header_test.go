@@ -0,0 +1,37 @@
+package packer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderCodec(t *testing.T) {
+	cases := []struct {
+		hc HeaderCodec
+		h  Header
+	}{
+		{Uint32Header, Header{ID: 1 << 20}},
+		{Uint16Header, Header{ID: 1 << 10}},
+		{SeqHeader, Header{ID: 7, Flags: 0x8001, Seq: 42}},
+		{TopicHeader, Header{Topic: "room.chat"}},
+	}
+
+	for _, c := range cases {
+		body, err := c.hc.PackHeader(c.h, []byte("value"))
+		assert.Nil(t, err, err)
+
+		h, value, err := c.hc.UnpackHeader(body)
+		assert.Nil(t, err, err)
+		assert.Equal(t, c.h, h, c.hc.String())
+		assert.Equal(t, "value", string(value))
+
+		_, _, err = c.hc.UnpackHeader(body[:1])
+		assert.True(t, IsProtocolError(err), c.hc.String())
+	}
+
+	_, err := Uint16Header.PackHeader(Header{ID: 1 << 16}, nil)
+	assert.NotNil(t, err)
+	_, err = TopicHeader.PackHeader(Header{}, nil)
+	assert.NotNil(t, err)
+}
@@ -10,3 +10,24 @@ type Packer interface {
 	Pack(io.Writer, []byte) error
 	String() string
 }
+
+// SessionPacker 由需要为每个session维护独立状态的packer实现,例如加密.
+// 注册到packer中的实例作为原型, session建立时调用NewSessionPacker获取该session独享的实例
+type SessionPacker interface {
+	Packer
+	NewSessionPacker() Packer
+}
+
+// Handshaker 由需要在session建立后与对端握手的packer实现.
+// Handshake在OnSession之后,读写循环开始之前调用,返回错误时session关闭
+type Handshaker interface {
+	Handshake(r io.Reader, w io.Writer) error
+}
+
+// NewSession 若p实现了SessionPacker,返回一个新的实例,否则返回p本身
+func NewSession(p Packer) Packer {
+	if sp, ok := p.(SessionPacker); ok {
+		return sp.NewSessionPacker()
+	}
+	return p
+}
@@ -1,5 +1,11 @@
 package packer
 
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
 var (
 	packers = map[string]Packer{}
 )
@@ -23,3 +29,23 @@ func MustGetPacker(name string) Packer {
 
 	panic("packer not register, name :" + name)
 }
+
+// GetPacker 获取指定名字对应的packer.
+// 若未注册,返回错误
+func GetPacker(name string) (Packer, error) {
+	if p, ok := packers[name]; ok {
+		return p, nil
+	}
+
+	return nil, errors.New("packer not register, name :" + name)
+}
+
+// ListPackers 返回所有已注册的packer名称,按名称排序
+func ListPackers() []string {
+	names := make([]string, 0, len(packers))
+	for name := range packers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
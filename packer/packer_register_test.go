@@ -0,0 +1,24 @@
+package packer_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	_ "github.com/MaxnSter/gnet/packer/plugins/packer_type_length_value"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPacker(t *testing.T) {
+	names := packer.ListPackers()
+	assert.Subset(t, names, []string{packer_length_value.Name, "tlv"})
+	assert.True(t, sort.StringsAreSorted(names))
+
+	p, err := packer.GetPacker(packer_length_value.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, packer_length_value.Name, p.String())
+
+	_, err = packer.GetPacker("not_exist")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,25 @@
+package internal
+
+import "io"
+
+// byteReader 将io.Reader包装为io.ByteReader
+type byteReader struct {
+	io.Reader
+	buf [1]byte
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.Reader, r.buf[:]); err != nil {
+		return 0, err
+	}
+	return r.buf[0], nil
+}
+
+// ByteReader 返回r对应的io.ByteReader, r已经实现io.ByteReader时直接返回r.
+// session中的reader为bufio.Reader, 不会被包装
+func ByteReader(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return &byteReader{Reader: r}
+}
@@ -0,0 +1,269 @@
+package packer_compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_type_length_value"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_varint"
+)
+
+// Algorithm 是压缩算法,同时作为每一帧的flag byte
+type Algorithm byte
+
+const (
+	None Algorithm = iota
+	Flate
+	Gzip
+	Zlib
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case None:
+		return "none"
+	case Flate:
+		return "flate"
+	case Gzip:
+		return "gzip"
+	case Zlib:
+		return "zlib"
+	default:
+		return fmt.Sprintf("algorithm(%d)", byte(a))
+	}
+}
+
+const (
+	// FlagSize flag byte的长度
+	FlagSize = 1
+
+	// DefaultThreshold 小于该长度的帧不压缩
+	DefaultThreshold = 1 << 9
+
+	// DefaultMaxLen 解压后的最大长度,防止decompression bomb, 8M
+	DefaultMaxLen = 1 << 23
+)
+
+// --------|----------------------------|
+// |  flag |     payload(compressed)    |
+// |   1   |                            |
+// --------|----------------------------|
+// |-------------inner packer value-----|
+//
+// 每一帧都带有flag,接收方根据flag解压,与发送方使用的算法和阈值无关,
+// 因此同一个server可以同时服务开启和未开启压缩的连接
+
+var (
+	_ packer.Wrapper       = (*compressPacker)(nil)
+	_ packer.SessionPacker = (*compressPacker)(nil)
+	_ packer.Handshaker    = (*compressPacker)(nil)
+)
+
+// Option 是compressPacker的配置
+type Option struct {
+	// Algorithm 发送时使用的压缩算法
+	Algorithm Algorithm
+
+	// Level 压缩级别,见compress/flate
+	Level int
+
+	// Threshold 小于该长度的帧不压缩
+	Threshold int
+
+	// MaxLen 解压后允许的最大长度
+	MaxLen int
+}
+
+func WithAlgorithm(a Algorithm) func(*Option) {
+	return func(o *Option) {
+		o.Algorithm = a
+	}
+}
+
+func WithLevel(level int) func(*Option) {
+	return func(o *Option) {
+		o.Level = level
+	}
+}
+
+func WithThreshold(threshold int) func(*Option) {
+	return func(o *Option) {
+		o.Threshold = threshold
+	}
+}
+
+func WithMaxLen(maxLen int) func(*Option) {
+	return func(o *Option) {
+		o.MaxLen = maxLen
+	}
+}
+
+type compressPacker struct {
+	Option
+	inner packer.Packer
+}
+
+// Unpack 使用inner packer解包,然后根据flag解压
+func (p *compressPacker) Unpack(reader io.Reader) ([]byte, error) {
+	frame, err := p.inner.Unpack(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(frame) < FlagSize {
+		return nil, packer.NewProtocolError(p.String(), "msg too short, missing flag")
+	}
+
+	flag, payload := Algorithm(frame[0]), frame[FlagSize:]
+	if flag == None {
+		return payload, nil
+	}
+
+	var r io.ReadCloser
+	switch flag {
+	case Flate:
+		r = flate.NewReader(bytes.NewReader(payload))
+	case Gzip:
+		r, err = gzip.NewReader(bytes.NewReader(payload))
+	case Zlib:
+		r, err = zlib.NewReader(bytes.NewReader(payload))
+	default:
+		return nil, packer.NewProtocolError(p.String(), "unknown compress flag:%d", byte(flag))
+	}
+	if err != nil {
+		return nil, packer.NewProtocolError(p.String(), "%s: %v", flag, err)
+	}
+	defer r.Close()
+
+	// 多读一个字节,用于判断是否超过MaxLen
+	value, err := ioutil.ReadAll(io.LimitReader(r, int64(p.MaxLen)+1))
+	if err != nil {
+		return nil, packer.NewProtocolError(p.String(), "%s: %v", flag, err)
+	}
+	if len(value) > p.MaxLen {
+		return nil, packer.NewProtocolError(p.String(), "decompressed msg too long, max:%d", p.MaxLen)
+	}
+
+	return value, nil
+}
+
+// Pack 长度不小于Threshold时压缩value,然后交给inner packer封包
+func (p *compressPacker) Pack(writer io.Writer, value []byte) error {
+	if p.Algorithm == None || len(value) < p.Threshold {
+		return p.inner.Pack(writer, p.raw(value))
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Grow(FlagSize + len(value)/2)
+	buf.WriteByte(byte(p.Algorithm))
+
+	var (
+		w   io.WriteCloser
+		err error
+	)
+	switch p.Algorithm {
+	case Flate:
+		w, err = flate.NewWriter(buf, p.Level)
+	case Gzip:
+		w, err = gzip.NewWriterLevel(buf, p.Level)
+	case Zlib:
+		w, err = zlib.NewWriterLevel(buf, p.Level)
+	default:
+		err = fmt.Errorf("unknown compress algorithm:%d", byte(p.Algorithm))
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(value); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	// 压缩后反而更大,直接发送原始数据
+	if buf.Len() >= FlagSize+len(value) {
+		return p.inner.Pack(writer, p.raw(value))
+	}
+	return p.inner.Pack(writer, buf.Bytes())
+}
+
+func (p *compressPacker) raw(value []byte) []byte {
+	frame := make([]byte, FlagSize+len(value))
+	frame[0] = byte(None)
+	copy(frame[FlagSize:], value)
+	return frame
+}
+
+// String 返回compressPacker的名称, 例如tlv+flate
+func (p *compressPacker) String() string {
+	return p.inner.String() + "+" + p.Algorithm.String()
+}
+
+// Unwrap 返回被包装的packer
+func (p *compressPacker) Unwrap() packer.Packer {
+	return p.inner
+}
+
+// NewSessionPacker 当inner需要per session实例时(例如加密),为每个session创建独立的inner
+func (p *compressPacker) NewSessionPacker() packer.Packer {
+	return &compressPacker{
+		Option: p.Option,
+		inner:  packer.NewSession(p.inner),
+	}
+}
+
+// Handshake 将握手转发给inner
+func (p *compressPacker) Handshake(r io.Reader, w io.Writer) error {
+	return packer.Handshake(p.inner, r, w)
+}
+
+// New 返回一个包装了inner的compressPacker,默认使用flate压缩
+func New(inner packer.Packer, opts ...func(*Option)) packer.Packer {
+	p := &compressPacker{
+		Option: Option{
+			Algorithm: Flate,
+			Level:     flate.DefaultCompression,
+			Threshold: DefaultThreshold,
+			MaxLen:    DefaultMaxLen,
+		},
+		inner: inner,
+	}
+
+	for _, f := range opts {
+		f(&p.Option)
+	}
+	return p
+}
+
+// Register 创建一个包装了inner的compressPacker,并以name注册到packer中
+func Register(name string, inner packer.Packer, opts ...func(*Option)) packer.Packer {
+	p := New(inner, opts...)
+	packer.RegisterPacker(name, p)
+	return p
+}
+
+// DefaultName 返回init中为inner注册的packer名称, 例如compress_lv
+func DefaultName(inner string) string {
+	return "compress_" + inner
+}
+
+// init 以默认配置(flate)包装lv, tlv与varint并注册, 以便通过名称选择, 例如在协商中.
+// 包装其他packer或使用其他配置时需要通过Register注册
+func init() {
+	for _, inner := range []packer.Packer{
+		packer_length_value.New(),
+		packer_type_length_value.New(),
+		packer_varint.New(),
+	} {
+		Register(DefaultName(inner.String()), inner)
+	}
+}
@@ -0,0 +1,57 @@
+package packer_compress
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressPacker_PackAndUnpack(t *testing.T) {
+	large := bytes.Repeat([]byte(`{"id":1,"msg":"compress"}`), 100)
+	small := []byte(`{"id":1}`)
+
+	for _, a := range []Algorithm{None, Flate, Gzip, Zlib} {
+		p := New(packer_length_value.New(), WithAlgorithm(a))
+		buf := &bytes.Buffer{}
+
+		assert.Nil(t, p.Pack(buf, large))
+		if a != None {
+			assert.True(t, buf.Len() < len(large), a.String())
+		}
+		assert.Nil(t, p.Pack(buf, small))
+
+		// 接收方不需要与发送方使用相同的算法
+		r := New(packer_length_value.New(), WithAlgorithm(None))
+		value, err := r.Unpack(buf)
+		assert.Nil(t, err, err)
+		assert.Equal(t, large, value)
+
+		value, err = r.Unpack(buf)
+		assert.Nil(t, err, err)
+		assert.Equal(t, small, value)
+	}
+}
+
+func TestCompressPacker_MaxLen(t *testing.T) {
+	p := New(packer_length_value.New(), WithAlgorithm(Gzip))
+	buf := &bytes.Buffer{}
+	assert.Nil(t, p.Pack(buf, make([]byte, 1<<20)))
+
+	r := New(packer_length_value.New(), WithMaxLen(1<<10))
+	_, err := r.Unpack(buf)
+	assert.True(t, packer.IsProtocolError(err))
+
+	assert.Equal(t, packer_length_value.Name, packer.Underlying(r).String())
+}
+
+func TestCompressPacker_Default(t *testing.T) {
+	for _, inner := range []string{"lv", "tlv", "varint"} {
+		p, err := packer.GetPacker(DefaultName(inner))
+		assert.Nil(t, err, err)
+		assert.Equal(t, inner+"+flate", p.String())
+		assert.Equal(t, inner, p.(packer.Wrapper).Unwrap().String())
+	}
+}
@@ -0,0 +1,289 @@
+package packer_crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Cipher 是对每一帧加密使用的AEAD算法
+type Cipher byte
+
+const (
+	AES256GCM Cipher = iota + 1
+	ChaCha20Poly1305
+)
+
+func (c Cipher) String() string {
+	switch c {
+	case AES256GCM:
+		return "aes256gcm"
+	case ChaCha20Poly1305:
+		return "chacha20poly1305"
+	default:
+		return fmt.Sprintf("cipher(%d)", byte(c))
+	}
+}
+
+const (
+	// Version 握手协议版本
+	Version = 1
+
+	// KeySize 密钥长度
+	KeySize = 32
+
+	flagKeyExchange = 1 << 0
+
+	// version + cipher + flags + public key(or random)
+	helloSize = 3 + KeySize
+)
+
+// 握手, session建立后双方各发送一个hello(通过inner packer封包):
+// ----------|----------|---------|---------------------------|
+// | version |  cipher  |  flags  |  X25519 public key/random |
+// |    1    |    1     |    1    |            32             |
+// ----------|----------|---------|---------------------------|
+//
+// 双方的hello作为salt, X25519共享密钥与预共享Key作为ikm,
+// 以各自hello中的32字节作为info,使用HKDF-SHA256派生出两个方向独立的密钥.
+// 每个方向的nonce为从0开始递增的计数器,不在帧中传输,
+// 因此被重放,重排或丢弃的帧都会导致解密失败,session随之关闭
+//
+// 握手之后每一帧:
+// ----------------------------------------|
+// |     ciphertext      |      tag        |
+// ----------------------------------------|
+// |--------inner packer value-------------|
+
+var (
+	_ packer.Wrapper       = (*cryptoPacker)(nil)
+	_ packer.SessionPacker = (*cryptoPacker)(nil)
+	_ packer.Handshaker    = (*cryptoPacker)(nil)
+)
+
+// Option 是cryptoPacker的配置
+type Option struct {
+	// Cipher 使用的AEAD算法,双方必须一致
+	Cipher Cipher
+
+	// Key 预共享密钥,不为空时参与密钥派生,双方必须一致
+	// 未开启KeyExchange时必须指定;开启时可以防止中间人攻击
+	Key []byte
+
+	// KeyExchange 为true时使用X25519交换临时密钥,每个session的密钥都不相同
+	KeyExchange bool
+}
+
+func WithCipher(c Cipher) func(*Option) {
+	return func(o *Option) {
+		o.Cipher = c
+	}
+}
+
+func WithKey(key []byte) func(*Option) {
+	return func(o *Option) {
+		o.Key = key
+	}
+}
+
+func WithKeyExchange(enable bool) func(*Option) {
+	return func(o *Option) {
+		o.KeyExchange = enable
+	}
+}
+
+// direction 是单个方向的加密状态
+type direction struct {
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+}
+
+// current 返回当前计数器对应的nonce,成功处理一帧之后计数器才递增
+func (d *direction) current() []byte {
+	binary.BigEndian.PutUint64(d.nonce[len(d.nonce)-8:], d.counter)
+	return d.nonce
+}
+
+// cryptoPacker 注册或传入module的是原型,只能用于创建session实例,
+// 每个session握手之后拥有独立的密钥与nonce计数器.
+// Pack只在writeLoop,Unpack只在readLoop中调用,因此两个方向的状态无需加锁
+type cryptoPacker struct {
+	Option
+	inner packer.Packer
+
+	send *direction
+	recv *direction
+}
+
+// Handshake 与对端交换hello并派生双方向的密钥
+func (p *cryptoPacker) Handshake(r io.Reader, w io.Writer) error {
+	if err := packer.Handshake(p.inner, r, w); err != nil {
+		return err
+	}
+
+	hello := make([]byte, helloSize)
+	hello[0], hello[1] = Version, byte(p.Cipher)
+
+	private := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, private); err != nil {
+		return err
+	}
+	if p.KeyExchange {
+		hello[2] |= flagKeyExchange
+
+		public, err := curve25519.X25519(private, curve25519.Basepoint)
+		if err != nil {
+			return err
+		}
+		copy(hello[3:], public)
+	} else {
+		copy(hello[3:], private)
+	}
+
+	if err := p.inner.Pack(w, hello); err != nil {
+		return errors.Wrap(err, "send hello failed")
+	}
+
+	peer, err := p.inner.Unpack(r)
+	if err != nil {
+		return errors.Wrap(err, "receive hello failed")
+	}
+	if len(peer) != helloSize || peer[0] != Version {
+		return packer.NewProtocolError(p.String(), "bad hello")
+	}
+	if Cipher(peer[1]) != p.Cipher || peer[2] != hello[2] {
+		return packer.NewProtocolError(p.String(), "hello mismatch, cipher:%s, flags:%d",
+			Cipher(peer[1]), peer[2])
+	}
+
+	var ikm []byte
+	if p.KeyExchange {
+		if ikm, err = curve25519.X25519(private, peer[3:]); err != nil {
+			return packer.NewProtocolError(p.String(), "bad public key: %v", err)
+		}
+	}
+	ikm = append(ikm, p.Key...)
+
+	// salt与发送顺序无关,双方计算结果一致
+	salt := make([]byte, 0, 2*helloSize)
+	if bytes.Compare(hello, peer) < 0 {
+		salt = append(append(salt, hello...), peer...)
+	} else {
+		salt = append(append(salt, peer...), hello...)
+	}
+
+	if p.send, err = p.direction(ikm, salt, hello[3:]); err != nil {
+		return err
+	}
+	p.recv, err = p.direction(ikm, salt, peer[3:])
+	return err
+}
+
+func (p *cryptoPacker) direction(ikm, salt, info []byte) (*direction, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, info), key); err != nil {
+		return nil, err
+	}
+
+	var (
+		aead cipher.AEAD
+		err  error
+	)
+	switch p.Cipher {
+	case AES256GCM:
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	case ChaCha20Poly1305:
+		aead, err = chacha20poly1305.New(key)
+	default:
+		err = errors.Errorf("unknown cipher:%d", byte(p.Cipher))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &direction{aead: aead, nonce: make([]byte, aead.NonceSize())}, nil
+}
+
+// Unpack 使用inner packer解包,然后解密并校验
+func (p *cryptoPacker) Unpack(reader io.Reader) ([]byte, error) {
+	if p.recv == nil {
+		return nil, errors.Errorf("%s: handshake not completed", p)
+	}
+
+	frame, err := p.inner.Unpack(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := p.recv.aead.Open(frame[:0], p.recv.current(), frame, nil)
+	if err != nil {
+		return nil, packer.NewProtocolError(p.String(), "decrypt failed, frame corrupted or replayed")
+	}
+	p.recv.counter++
+
+	return value, nil
+}
+
+// Pack 加密value,然后交给inner packer封包
+func (p *cryptoPacker) Pack(writer io.Writer, value []byte) error {
+	if p.send == nil {
+		return errors.Errorf("%s: handshake not completed", p)
+	}
+
+	frame := p.send.aead.Seal(nil, p.send.current(), value, nil)
+	p.send.counter++
+	return p.inner.Pack(writer, frame)
+}
+
+// String 返回cryptoPacker的名称, 例如lv+aes256gcm
+func (p *cryptoPacker) String() string {
+	return p.inner.String() + "+" + p.Cipher.String()
+}
+
+// Unwrap 返回被包装的packer
+func (p *cryptoPacker) Unwrap() packer.Packer {
+	return p.inner
+}
+
+// NewSessionPacker 为session创建一个独立的实例,握手后才可以使用
+func (p *cryptoPacker) NewSessionPacker() packer.Packer {
+	return &cryptoPacker{
+		Option: p.Option,
+		inner:  packer.NewSession(p.inner),
+	}
+}
+
+// New 返回一个包装了inner的cryptoPacker原型,默认使用AES-256-GCM和X25519密钥交换
+func New(inner packer.Packer, opts ...func(*Option)) packer.Packer {
+	p := &cryptoPacker{
+		Option: Option{
+			Cipher:      AES256GCM,
+			KeyExchange: true,
+		},
+		inner: inner,
+	}
+
+	for _, f := range opts {
+		f(&p.Option)
+	}
+
+	if !p.KeyExchange && len(p.Key) == 0 {
+		panic("packer_crypto: key required when key exchange disabled")
+	}
+	return p
+}
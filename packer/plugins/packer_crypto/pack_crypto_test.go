@@ -0,0 +1,91 @@
+package packer_crypto
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	"github.com/stretchr/testify/assert"
+)
+
+// handshake 在本地tcp连接的两端分别创建session实例并完成握手
+func handshake(t *testing.T, server, client packer.Packer) (sp, cp packer.Packer, sc, cc net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+
+	cc, err = net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err, err)
+	sc = <-accepted
+
+	sp, cp = packer.NewSession(server), packer.NewSession(client)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- sp.(packer.Handshaker).Handshake(sc, sc)
+	}()
+	assert.Nil(t, cp.(packer.Handshaker).Handshake(cc, cc))
+	assert.Nil(t, <-errCh)
+	return
+}
+
+func TestCryptoPacker_PackAndUnpack(t *testing.T) {
+	for _, c := range []Cipher{AES256GCM, ChaCha20Poly1305} {
+		proto := New(packer_length_value.New(), WithCipher(c))
+		sp, cp, sc, cc := handshake(t, proto, proto)
+
+		r := bufio.NewReader(sc)
+		for _, msg := range []string{"hello", "", "world"} {
+			assert.Nil(t, cp.Pack(cc, []byte(msg)))
+
+			value, err := sp.Unpack(r)
+			assert.Nil(t, err, err)
+			assert.Equal(t, msg, string(value), c.String())
+		}
+
+		sc.Close()
+		cc.Close()
+	}
+}
+
+func TestCryptoPacker_Replay(t *testing.T) {
+	proto := New(packer_length_value.New(), WithKey([]byte("psk")))
+	sp, cp, sc, cc := handshake(t, proto, proto)
+	defer sc.Close()
+	defer cc.Close()
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, cp.Pack(buf, []byte("pay 100")))
+	frame := buf.Bytes()
+
+	_, err := sp.Unpack(bytes.NewReader(frame))
+	assert.Nil(t, err, err)
+
+	// 同一帧再次发送
+	_, err = sp.Unpack(bytes.NewReader(frame))
+	assert.True(t, packer.IsProtocolError(err))
+}
+
+func TestCryptoPacker_KeyMismatch(t *testing.T) {
+	server := New(packer_length_value.New(), WithKeyExchange(false), WithKey([]byte("server")))
+	client := New(packer_length_value.New(), WithKeyExchange(false), WithKey([]byte("client")))
+	sp, cp, sc, cc := handshake(t, server, client)
+	defer sc.Close()
+	defer cc.Close()
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, cp.Pack(buf, []byte("secret")))
+	_, err := sp.Unpack(buf)
+	assert.True(t, packer.IsProtocolError(err))
+
+	// 原型不能直接使用
+	assert.NotNil(t, server.Pack(buf, []byte("secret")))
+}
@@ -0,0 +1,154 @@
+package packer_delimiter
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"io"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/packer/plugins/internal"
+	"github.com/MaxnSter/gnet/util"
+)
+
+const (
+	// Name 以"\n"分割消息的packer名称
+	Name = "line"
+
+	// NameCRLF 以"\r\n"分割消息的packer名称
+	NameCRLF = "crlf"
+
+	// MaxLen 64K
+	MaxLen = 1 << 16
+)
+
+// -------------------------|-------------
+// |          value         |  delimiter |
+// -------------------------|-------------
+// 适用于redis inline command, smtp, line-json之类的文本协议
+
+var (
+	_ packer.Packer = (*delimiterPacker)(nil)
+)
+
+// Option 是delimiterPacker的配置
+type Option struct {
+	// Name packer的名称
+	Name string
+
+	// Delimiter 消息分隔符
+	Delimiter []byte
+
+	// MaxLen 单条消息的最大长度,包括分隔符
+	MaxLen int
+
+	// KeepDelimiter 为true时,Unpack返回的消息保留分隔符
+	KeepDelimiter bool
+}
+
+func WithName(name string) func(*Option) {
+	return func(o *Option) {
+		o.Name = name
+	}
+}
+
+func WithDelimiter(delim []byte) func(*Option) {
+	return func(o *Option) {
+		o.Delimiter = delim
+	}
+}
+
+func WithMaxLen(maxLen int) func(*Option) {
+	return func(o *Option) {
+		o.MaxLen = maxLen
+	}
+}
+
+func WithKeepDelimiter(keep bool) func(*Option) {
+	return func(o *Option) {
+		o.KeepDelimiter = keep
+	}
+}
+
+type delimiterPacker struct {
+	Option
+}
+
+// Unpack 一直读取直到遇到分隔符,返回分隔符之前(或包括分隔符)的[]byte
+func (p *delimiterPacker) Unpack(reader io.Reader) (value []byte, err error) {
+	br := internal.ByteReader(reader)
+
+	var c byte
+	for !bytes.HasSuffix(value, p.Delimiter) {
+		if len(value) >= p.MaxLen {
+			return nil, errors.Errorf("msg too long, max:%d", p.MaxLen)
+		}
+
+		if c, err = br.ReadByte(); err != nil {
+			// 读到一半就断开,同样视为连接关闭
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		value = append(value, c)
+	}
+
+	if !p.KeepDelimiter {
+		value = value[:len(value)-len(p.Delimiter)]
+	}
+	return
+}
+
+// Pack 在value之后追加分隔符并写入writer,若value已经以分隔符结尾,则不再追加
+// value中间不允许出现分隔符,否则对端会将其拆分为多条消息
+func (p *delimiterPacker) Pack(writer io.Writer, value []byte) error {
+	body := value
+	if bytes.HasSuffix(body, p.Delimiter) {
+		body = body[:len(body)-len(p.Delimiter)]
+	}
+
+	if bytes.Contains(body, p.Delimiter) {
+		return errors.Errorf("msg contains delimiter %q", p.Delimiter)
+	}
+
+	totalLen := len(body) + len(p.Delimiter)
+	if totalLen > p.MaxLen {
+		return errors.Errorf("msg too long, max:%d, actual:%d", p.MaxLen, totalLen)
+	}
+
+	pack := make([]byte, totalLen)
+	copy(pack, body)
+	copy(pack[len(body):], p.Delimiter)
+
+	return util.WriteFull(writer, pack)
+}
+
+// String 返回delimiterPacker的名称
+func (p *delimiterPacker) String() string {
+	return p.Name
+}
+
+func init() {
+	packer.RegisterPacker(Name, New())
+	packer.RegisterPacker(NameCRLF, New(WithName(NameCRLF), WithDelimiter([]byte("\r\n"))))
+}
+
+// New 返回一个delimiterPacker,默认以"\n"分割消息且不保留分隔符
+func New(opts ...func(*Option)) packer.Packer {
+	p := &delimiterPacker{
+		Option: Option{
+			Name:      Name,
+			Delimiter: []byte("\n"),
+			MaxLen:    MaxLen,
+		},
+	}
+
+	for _, f := range opts {
+		f(&p.Option)
+	}
+
+	if len(p.Delimiter) == 0 {
+		panic("packer_delimiter: empty delimiter")
+	}
+	return p
+}
@@ -0,0 +1,52 @@
+package packer_delimiter
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelimiterPacker_Unpack(t *testing.T) {
+	p := packer.MustGetPacker(NameCRLF)
+	r := bufio.NewReader(strings.NewReader("PING\r\nSET k v\r\n\r\nhalf"))
+
+	for _, expect := range []string{"PING", "SET k v", ""} {
+		line, err := p.Unpack(r)
+		assert.Nil(t, err, err)
+		assert.Equal(t, expect, string(line))
+	}
+
+	_, err := p.Unpack(r)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDelimiterPacker_KeepDelimiter(t *testing.T) {
+	p := New(WithKeepDelimiter(true))
+
+	line, err := p.Unpack(strings.NewReader("{\"id\":1}\n"))
+	assert.Nil(t, err, err)
+	assert.Equal(t, "{\"id\":1}\n", string(line))
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, p.Pack(buf, line))
+	assert.Equal(t, "{\"id\":1}\n", buf.String())
+}
+
+func TestDelimiterPacker_Pack(t *testing.T) {
+	p := New(WithMaxLen(8))
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, p.Pack(buf, []byte("hello")))
+	assert.Equal(t, "hello\n", buf.String())
+
+	assert.NotNil(t, p.Pack(buf, []byte("he\nllo")))
+	assert.NotNil(t, p.Pack(buf, []byte("too long line")))
+
+	_, err := p.Unpack(strings.NewReader("too long line\n"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,102 @@
+package packer_fixed_length
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"strconv"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/util"
+)
+
+const (
+	// the name prefix of pack_fixed_length
+	Name = "fixed"
+)
+
+// ------------------------------------------
+// |             value(N bytes)             |
+// ------------------------------------------
+// 每一帧的长度都固定为N字节,没有任何头部
+
+var (
+	_ packer.Packer = (*fixedLengthPacker)(nil)
+)
+
+type fixedLengthPacker struct {
+	name   string
+	length int
+}
+
+// Unpack 读取恰好length个字节
+func (p *fixedLengthPacker) Unpack(reader io.Reader) (value []byte, err error) {
+	value = make([]byte, p.length)
+	if _, err = io.ReadFull(reader, value); err != nil {
+		// readFull把io.EoF视为io.ErrUnexpectedEOF
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+
+		return nil, err
+	}
+
+	return
+}
+
+// Pack 写入value,value的长度必须恰好为length
+func (p *fixedLengthPacker) Pack(writer io.Writer, value []byte) error {
+	if len(value) != p.length {
+		return errors.Errorf("msg length mismatch, expect:%d, actual:%d", p.length, len(value))
+	}
+
+	return util.WriteFull(writer, value)
+}
+
+// String 返回fixedLengthPacker的名称
+func (p *fixedLengthPacker) String() string {
+	return p.name
+}
+
+// Length 返回每一帧的长度
+func (p *fixedLengthPacker) Length() int {
+	return p.length
+}
+
+// New 返回一个每帧length字节的packer,名称为Name
+func New(length int) packer.Packer {
+	return NewWithName(Name, length)
+}
+
+// NewWithName 与New相同,但可以指定packer的名称
+func NewWithName(name string, length int) packer.Packer {
+	if length <= 0 {
+		panic("packer_fixed_length: length must be positive")
+	}
+
+	return &fixedLengthPacker{
+		name:   name,
+		length: length,
+	}
+}
+
+// Register 创建一个每帧length字节的packer,并以name注册到packer中.
+// DefaultLengths以外的帧长度需要通过Register注册
+func Register(name string, length int) packer.Packer {
+	p := NewWithName(name, length)
+	packer.RegisterPacker(name, p)
+	return p
+}
+
+// DefaultLengths 是init中注册的帧长度, 名称见DefaultName
+var DefaultLengths = []int{4, 8, 16, 32, 64, 128, 256, 512, 1024}
+
+// DefaultName 返回init中为length注册的packer名称, 例如fixed_length_16
+func DefaultName(length int) string {
+	return "fixed_length_" + strconv.Itoa(length)
+}
+
+func init() {
+	for _, n := range DefaultLengths {
+		Register(DefaultName(n), n)
+	}
+}
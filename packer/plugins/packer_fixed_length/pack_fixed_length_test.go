@@ -0,0 +1,48 @@
+package packer_fixed_length
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+// registered 记录已注册的测试packer数量, 注册无法撤销, 每次运行使用不同的名称(go test -count=n)
+var registered int
+
+func TestFixedLengthPacker_PackAndUnpack(t *testing.T) {
+	registered++
+	name := fmt.Sprintf("fixed4_%d", registered)
+	Register(name, 4)
+	p := packer.MustGetPacker(name)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, p.Pack(buf, []byte("abcd")))
+	assert.Nil(t, p.Pack(buf, []byte("efgh")))
+	assert.NotNil(t, p.Pack(buf, []byte("abc")))
+	buf.WriteString("ij")
+
+	for _, expect := range []string{"abcd", "efgh"} {
+		value, err := p.Unpack(buf)
+		assert.Nil(t, err, err)
+		assert.Equal(t, expect, string(value))
+	}
+
+	_, err := p.Unpack(buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFixedLengthPacker_Default(t *testing.T) {
+	for _, n := range DefaultLengths {
+		p, err := packer.GetPacker(DefaultName(n))
+		assert.Nil(t, err, err)
+		assert.Equal(t, n, p.(*fixedLengthPacker).Length())
+		assert.Equal(t, DefaultName(n), p.String())
+	}
+
+	_, err := packer.GetPacker(DefaultName(3))
+	assert.NotNil(t, err)
+}
@@ -24,12 +24,31 @@ const (
 // |  Length   |             value	        |
 // |    4      |                            |
 // ------------------------------------------
+//
+// 指定checksum时,value之后追加4字节的校验值,Length包含校验值的长度
+// ------------|---------------|---------------|
+// |  Length   |     value     |   checksum    |
+// |    4      |               |       4       |
+// ---------------------------------------------
 
 var (
 	_ packer.Packer = (*lvPacker)(nil)
 )
 
+// Option 是lvPacker的配置
+type Option struct {
+	// Checksum 不为nil时,每一帧都带有校验值trailer
+	Checksum packer.Checksum
+}
+
+func WithChecksum(c packer.Checksum) func(*Option) {
+	return func(o *Option) {
+		o.Checksum = c
+	}
+}
+
 type lvPacker struct {
+	Option
 }
 
 // Unpack 使用length-value的解包方式读消息,然后返回value对应的[]byte
@@ -55,7 +74,14 @@ func (p *lvPacker) Unpack(reader io.Reader) (value []byte, err error) {
 
 	// 根据长度读取对应长度的字节
 	value = make([]byte, length)
-	_, err = io.ReadFull(reader, value)
+	if _, err = io.ReadFull(reader, value); err != nil {
+		return
+	}
+
+	// 校验并去掉checksum trailer
+	if p.Checksum != nil {
+		value, err = packer.VerifyChecksum(Name, p.Checksum, value)
+	}
 
 	return
 }
@@ -64,6 +90,9 @@ func (p *lvPacker) Unpack(reader io.Reader) (value []byte, err error) {
 func (p *lvPacker) Pack(writer io.Writer, value []byte) error {
 	// 对着上图,totalLen := lenSize + valueLen
 	valueLen := len(value)
+	if p.Checksum != nil {
+		valueLen += packer.ChecksumSize
+	}
 	totalLen := LengthSize + valueLen
 	pack := make([]byte, totalLen)
 
@@ -73,6 +102,11 @@ func (p *lvPacker) Pack(writer io.Writer, value []byte) error {
 	// 写入value段
 	copy(pack[LengthSize:], value)
 
+	// 写入checksum段
+	if p.Checksum != nil {
+		packer.PutChecksum(p.Checksum, pack[LengthSize+len(value):], value)
+	}
+
 	// 一直写,虽然runtime在write socket时也会保证全部写入(源码里有)
 	// 但这里的writer对应的不一定是io.conn,也有可能是包装buffer之后的
 	// writer,所以,还是需要调用writeFull滴
@@ -92,6 +126,11 @@ func init() {
 	packer.RegisterPacker(Name, &lvPacker{})
 }
 
-func New() packer.Packer {
-	return &lvPacker{}
+// New 返回一个lvPacker,可通过WithChecksum开启帧校验
+func New(opts ...func(*Option)) packer.Packer {
+	p := &lvPacker{}
+	for _, f := range opts {
+		f(&p.Option)
+	}
+	return p
 }
@@ -0,0 +1,26 @@
+package packer_length_value
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLvPacker_Checksum(t *testing.T) {
+	p := New(WithChecksum(packer.CRC32))
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, p.Pack(buf, []byte("lv")))
+	assert.Equal(t, LengthSize+len("lv")+packer.ChecksumSize, buf.Len())
+	frame := append([]byte(nil), buf.Bytes()...)
+
+	value, err := p.Unpack(buf)
+	assert.Nil(t, err, err)
+	assert.Equal(t, "lv", string(value))
+
+	frame[len(frame)-1] ^= 0xff
+	_, err = p.Unpack(bytes.NewReader(frame))
+	assert.True(t, packer.IsProtocolError(err))
+}
@@ -7,37 +7,53 @@ import (
 )
 
 const (
+	// the name of pack_raw
+	Name = "raw"
+
+	// BufSize 单个chunk的最大长度
 	BufSize = 1 << 10 * 8
 )
 
+// rawPacker 是stream chunk模式的packer,不提供任何消息边界语义:
+// Unpack每次返回当前已到达的一段字节流(1 ~ BufSize字节),
+// 一条逻辑消息可能被拆分成多个chunk,多条逻辑消息也可能合并在一个chunk中,
+// 由使用方自行处理流的重组,需要消息边界时请使用lv,tlv,varint,line或fixed
 type rawPacker struct {
 }
 
-func (p *rawPacker) Unpack(reader io.Reader) (buf []byte, err error) {
-	var nRead int
-
-	buf = make([]byte, BufSize)
-	nRead, err = reader.Read(buf)
-	buf = buf[:nRead]
+var (
+	_ packer.Packer = (*rawPacker)(nil)
+)
 
-	if err != nil {
-		return
+// Unpack 读取一个chunk,保证返回的chunk至少包含一个字节.
+// 若读取到数据的同时发生错误,先返回数据,错误在下一次Unpack时返回
+func (p *rawPacker) Unpack(reader io.Reader) (chunk []byte, err error) {
+	buf := make([]byte, BufSize)
+	for {
+		nRead, err := reader.Read(buf)
+		if nRead > 0 {
+			// 出错前读到的数据依然有效,错误会在下次Read时再次出现
+			return buf[:nRead], nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
 	}
-
-	return
 }
 
-// Pack使用指定的coder序列化msg然后封包,最后写入writer
-func (p *rawPacker) Pack(writer io.Writer, buf []byte) error {
-	return util.WriteFull(writer, buf)
+// Pack 将chunk原样写入writer,直到全部写入或发生错误
+func (p *rawPacker) Pack(writer io.Writer, chunk []byte) error {
+	return util.WriteFull(writer, chunk)
 }
 
+// String 返回rawPacker的名称
 func (p *rawPacker) String() string {
-	return "raw"
+	return Name
 }
 
 func init() {
-	packer.RegisterPacker("raw", &rawPacker{})
+	packer.RegisterPacker(Name, &rawPacker{})
 }
 
 func New() packer.Packer {
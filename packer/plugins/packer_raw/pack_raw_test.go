@@ -0,0 +1,24 @@
+package packer_raw
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawPacker_Unpack(t *testing.T) {
+	p := packer.MustGetPacker(Name)
+
+	// 数据与io.EOF同时返回时,数据不能丢失
+	r := iotest.DataErrReader(strings.NewReader("chunk"))
+	chunk, err := p.Unpack(r)
+	assert.Nil(t, err, err)
+	assert.Equal(t, "chunk", string(chunk))
+
+	_, err = p.Unpack(r)
+	assert.Equal(t, io.EOF, err)
+}
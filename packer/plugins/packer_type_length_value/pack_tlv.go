@@ -10,7 +10,8 @@ import (
 )
 
 var (
-	_ packer.Packer = (*tlvPacker)(nil)
+	_ packer.Packer      = (*tlvPacker)(nil)
+	_ packer.HeaderCodec = (*tlvPacker)(nil)
 )
 
 const (
@@ -26,8 +27,27 @@ const (
 // |    4      |       4       |   msg  	|
 // ------------------------------------------
 // |           |--------------body----------|
+//
+// 指定checksum时,body之后追加4字节的校验值,Length包含校验值的长度
+// ------------|---------------|------------|---------------|
+// |  Length   |   Type(msgId) |   value	 |   checksum    |
+// |    4      |       4       |   msg  	 |       4       |
+// ---------------------------------------------------------
+
+// Option 是tlvPacker的配置
+type Option struct {
+	// Checksum 不为nil时,每一帧都带有校验值trailer
+	Checksum packer.Checksum
+}
+
+func WithChecksum(c packer.Checksum) func(*Option) {
+	return func(o *Option) {
+		o.Checksum = c
+	}
+}
 
 type tlvPacker struct {
+	Option
 }
 
 func UnpackMsgId(body []byte) (msgId uint32, value []byte) {
@@ -43,6 +63,16 @@ func PackMsgId(msgId uint32, value []byte) (body []byte) {
 	return
 }
 
+// PackHeader 将msgId写入body的Type段, 见packer.Uint32Header
+func (p *tlvPacker) PackHeader(h packer.Header, value []byte) ([]byte, error) {
+	return packer.Uint32Header.PackHeader(h, value)
+}
+
+// UnpackHeader 从body的Type段解析msgId, 见packer.Uint32Header
+func (p *tlvPacker) UnpackHeader(body []byte) (packer.Header, []byte, error) {
+	return packer.Uint32Header.UnpackHeader(body)
+}
+
 func (p *tlvPacker) Unpack(reader io.Reader) (body []byte, err error) {
 
 	//读取长度段
@@ -58,11 +88,15 @@ func (p *tlvPacker) Unpack(reader io.Reader) (body []byte, err error) {
 
 	//解析长度段
 	length := binary.BigEndian.Uint32(header)
+	minLength := uint32(TypeBytes)
+	if p.Checksum != nil {
+		minLength += packer.ChecksumSize
+	}
 	if length > MaxLength {
 		err = errors.Errorf("msg too long, max:%d, actual:%d", MaxLength, length)
 	}
-	if length <= TypeBytes {
-		err = errors.Errorf("msg too short, min:%d, actual:%d", TypeBytes, length)
+	if length <= minLength {
+		err = errors.Errorf("msg too short, min:%d, actual:%d", minLength, length)
 	}
 	if err != nil {
 		return
@@ -71,7 +105,14 @@ func (p *tlvPacker) Unpack(reader io.Reader) (body []byte, err error) {
 	//根据length,读取对应字节数
 	body = make([]byte, length)
 	//从body中解析messageId,根据messageId,我们可以获取该messageId对应的meta信息
-	_, err = io.ReadFull(reader, body)
+	if _, err = io.ReadFull(reader, body); err != nil {
+		return
+	}
+
+	//校验并去掉checksum trailer
+	if p.Checksum != nil {
+		body, err = packer.VerifyChecksum(Name, p.Checksum, body)
+	}
 
 	return
 }
@@ -87,6 +128,12 @@ func (p *tlvPacker) Pack(writer io.Writer, body []byte) error {
 	//对应上图 Type + Value总的长度,
 	bodyLen := len(body)
 
+	//对应上图, 带有checksum时, Length的值包含checksum
+	if p.Checksum != nil {
+		totalLen += packer.ChecksumSize
+		bodyLen += packer.ChecksumSize
+	}
+
 	pack := make([]byte, totalLen)
 
 	// put length
@@ -96,6 +143,11 @@ func (p *tlvPacker) Pack(writer io.Writer, body []byte) error {
 	// put value([]byte after encode)
 	copy(pack[LengthBytes:], body)
 
+	// put checksum of Type + Value
+	if p.Checksum != nil {
+		packer.PutChecksum(p.Checksum, pack[LengthBytes+len(body):], body)
+	}
+
 	return util.WriteFull(writer, pack)
 }
 
@@ -108,6 +160,11 @@ func init() {
 	packer.RegisterPacker(Name, &tlvPacker{})
 }
 
-func New() packer.Packer {
-	return &tlvPacker{}
+// New 返回一个tlvPacker,可通过WithChecksum开启帧校验
+func New(opts ...func(*Option)) packer.Packer {
+	p := &tlvPacker{}
+	for _, f := range opts {
+		f(&p.Option)
+	}
+	return p
 }
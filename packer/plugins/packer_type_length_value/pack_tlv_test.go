@@ -0,0 +1,29 @@
+package packer_type_length_value
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTlvPacker_Checksum(t *testing.T) {
+	p := New(WithChecksum(packer.CRC32C))
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, p.Pack(buf, PackMsgId(1, []byte("tlv"))))
+	frame := append([]byte(nil), buf.Bytes()...)
+
+	body, err := p.Unpack(buf)
+	assert.Nil(t, err, err)
+	msgId, value := UnpackMsgId(body)
+	assert.Equal(t, uint32(1), msgId)
+	assert.Equal(t, "tlv", string(value))
+
+	// 篡改value中的一个字节
+	frame[LengthBytes+TypeBytes] ^= 0xff
+	_, err = p.Unpack(bytes.NewReader(frame))
+	assert.IsType(t, &packer.ChecksumError{}, err)
+	assert.True(t, packer.IsProtocolError(err))
+}
@@ -0,0 +1,95 @@
+package packer_varint
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/packer/plugins/internal"
+	"github.com/MaxnSter/gnet/util"
+)
+
+const (
+	// the name of pack_varint
+	Name = "varint"
+
+	// MaxLen 8M
+	MaxLen = 1 << 23
+)
+
+// ------------|---------------|-------------
+// |  Length   |             value	        |
+// |  uvarint  |                            |
+// ------------------------------------------
+// 与protobuf的writeDelimitedTo/parseDelimitedFrom格式一致
+
+var (
+	_ packer.Packer = (*varintPacker)(nil)
+)
+
+type varintPacker struct {
+	maxLen uint64
+}
+
+// Unpack 读取uvarint长度前缀,然后返回value对应的[]byte
+func (p *varintPacker) Unpack(reader io.Reader) (value []byte, err error) {
+	br := internal.ByteReader(reader)
+
+	// 读取长度段
+	length, err := binary.ReadUvarint(br)
+	if err != nil {
+		// 长度段读到一半就断开,同样视为连接关闭
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+
+		return
+	}
+
+	if length > p.maxLen {
+		err = errors.Errorf("msg too long, max:%d, actual:%d", p.maxLen, length)
+		return
+	}
+
+	// 根据长度读取对应长度的字节
+	value = make([]byte, length)
+	_, err = io.ReadFull(reader, value)
+
+	return
+}
+
+// Pack 使用uvarint长度前缀对消息封包,并保证全部写入writer,直到错误
+func (p *varintPacker) Pack(writer io.Writer, value []byte) error {
+	if uint64(len(value)) > p.maxLen {
+		return errors.Errorf("msg too long, max:%d, actual:%d", p.maxLen, len(value))
+	}
+
+	pack := make([]byte, binary.MaxVarintLen64+len(value))
+
+	// 写入length段
+	n := binary.PutUvarint(pack, uint64(len(value)))
+
+	// 写入value段
+	n += copy(pack[n:], value)
+
+	return util.WriteFull(writer, pack[:n])
+}
+
+// String 返回varintPacker的名称
+func (p *varintPacker) String() string {
+	return Name
+}
+
+func init() {
+	packer.RegisterPacker(Name, New())
+}
+
+func New() packer.Packer {
+	return NewWithMaxLen(MaxLen)
+}
+
+// NewWithMaxLen 返回一个指定消息最大长度的varintPacker
+func NewWithMaxLen(maxLen int) packer.Packer {
+	return &varintPacker{maxLen: uint64(maxLen)}
+}
@@ -0,0 +1,48 @@
+package packer_varint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarintPacker_PackAndUnpack(t *testing.T) {
+	p := packer.MustGetPacker(Name)
+
+	buf := &bytes.Buffer{}
+	body := bytes.Repeat([]byte("varint"), 100)
+	assert.Nil(t, p.Pack(buf, body))
+	assert.Nil(t, p.Pack(buf, []byte{}))
+
+	// 长度前缀与protobuf delimited格式一致
+	length, err := binary.ReadUvarint(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(len(body)), length)
+
+	value, err := p.Unpack(buf)
+	assert.Nil(t, err, err)
+	assert.Equal(t, body, value)
+
+	value, err = p.Unpack(buf)
+	assert.Nil(t, err, err)
+	assert.Empty(t, value)
+
+	_, err = p.Unpack(buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestVarintPacker_MaxLen(t *testing.T) {
+	p := NewWithMaxLen(4)
+
+	buf := &bytes.Buffer{}
+	assert.NotNil(t, p.Pack(buf, []byte("too long")))
+
+	header := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(header, 5)
+	_, err := p.Unpack(bytes.NewReader(header[:n]))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,31 @@
+package packer
+
+import "io"
+
+// Wrapper 由包装了其他packer的packer实现,例如压缩,加密
+type Wrapper interface {
+	Packer
+
+	// Unwrap 返回被包装的packer
+	Unwrap() Packer
+}
+
+// Underlying 逐层解开Wrapper,返回最内层的packer
+func Underlying(p Packer) Packer {
+	for {
+		w, ok := p.(Wrapper)
+		if !ok {
+			return p
+		}
+		p = w.Unwrap()
+	}
+}
+
+// Handshake 若p实现了Handshaker则与对端握手,否则直接返回nil.
+// 供Wrapper转发握手给被包装的packer
+func Handshake(p Packer, r io.Reader, w io.Writer) error {
+	if h, ok := p.(Handshaker); ok {
+		return h.Handshake(r, w)
+	}
+	return nil
+}
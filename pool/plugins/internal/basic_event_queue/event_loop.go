@@ -1,24 +1,63 @@
 package basic_event_queue
 
 import (
+	"context"
 	"errors"
 	"sync"
+
+	"github.com/MaxnSter/gnet/pool"
+)
+
+var (
+	ErrFull    = errors.New("queue size limit")
+	ErrStopped = pool.ErrStopped
 )
 
+// Stats 是EventQueue的统计信息, 见pool.Stats
+type Stats = pool.Stats
+
+// EventQueue 是一个单goroutine按FIFO顺序执行任务的队列.
+// 任务保存在ring buffer中, queueSize限制Put与PutContext,
+// MustPut在队列已满时扩容ring buffer, 不会阻塞也不会打乱顺序.
+// maxSize为正数时Put与PutContext以maxSize为上限, 超出queueSize的部分同样通过扩容缓存, MustPut不受限制
 type EventQueue struct {
-	queue     chan func()
+	guard    sync.Mutex
+	notEmpty *sync.Cond
+	notFull  chan struct{} // 队列从满变为不满时关闭, 用于PutContext
+	buf      []func()
+	head, n  int
+	stopped  bool
+	stats    Stats
+
 	closeDone chan struct{}
 
 	queueSize int
+	maxSize   int
 	sync.Once
 }
 
 func NewEventQueue(queueSize int) *EventQueue {
+	return NewEventQueueWithLimit(queueSize, 0)
+}
+
+// NewEventQueueWithLimit 创建Put与PutContext最多缓存maxSize个任务的队列, maxSize为0时为queueSize.
+// maxSize小于queueSize时使用queueSize
+func NewEventQueueWithLimit(queueSize, maxSize int) *EventQueue {
+	if queueSize <= 0 {
+		panic("non-positive queue size")
+	}
+	if maxSize < queueSize {
+		maxSize = queueSize
+	}
+
 	loop := &EventQueue{
-		queue:     make(chan func(), queueSize),
+		notFull:   make(chan struct{}),
+		buf:       make([]func(), queueSize),
 		closeDone: make(chan struct{}),
 		queueSize: queueSize,
+		maxSize:   maxSize,
 	}
+	loop.notEmpty = sync.NewCond(&loop.guard)
 
 	return loop
 }
@@ -30,21 +69,78 @@ func (loop *EventQueue) Run() {
 }
 
 func (loop *EventQueue) loop() {
-	for cb := range loop.queue {
-		if cb == nil {
+	for {
+		cb, ok := loop.pop()
+		if !ok {
 			break
 		}
 
-		cb()
+		if cb != nil {
+			cb()
+		}
+	}
+
+	close(loop.closeDone)
+}
+
+// pop 阻塞直到取出队首的任务, 队列已停止并且为空时返回false
+func (loop *EventQueue) pop() (func(), bool) {
+	loop.guard.Lock()
+	defer loop.guard.Unlock()
+
+	for loop.n == 0 {
+		if loop.stopped {
+			return nil, false
+		}
+		loop.notEmpty.Wait()
+	}
+
+	cb := loop.buf[loop.head]
+	loop.buf[loop.head] = nil
+	loop.head = (loop.head + 1) % len(loop.buf)
+	loop.n--
+
+	if loop.n == loop.maxSize-1 {
+		close(loop.notFull)
+		loop.notFull = make(chan struct{})
+	}
+	if loop.n == 0 && len(loop.buf) > loop.queueSize {
+		// MustPut扩容之后, 队列清空时恢复原来的大小
+		loop.buf, loop.head = make([]func(), loop.queueSize), 0
+	}
+	return cb, true
+}
+
+// push 将f放入队尾, 调用方持有guard
+func (loop *EventQueue) push(f func()) {
+	if loop.n == len(loop.buf) {
+		buf := make([]func(), len(loop.buf)*2)
+		m := copy(buf, loop.buf[loop.head:])
+		copy(buf[m:], loop.buf[:loop.head])
+		loop.buf, loop.head = buf, 0
 	}
 
-	if loop.closeDone != nil {
-		close(loop.closeDone)
+	loop.buf[(loop.head+loop.n)%len(loop.buf)] = f
+	loop.n++
+	if loop.n > loop.stats.Peak {
+		loop.stats.Peak = loop.n
 	}
+	loop.notEmpty.Signal()
 }
 
+// StopAsync 停止队列, 已经投放的任务全部执行完毕时done active.
+// 之后投放的任务不再执行
 func (loop *EventQueue) StopAsync() (done <-chan struct{}) {
-	loop.queue <- nil
+	loop.guard.Lock()
+	if !loop.stopped {
+		loop.stopped = true
+		// 唤醒loop与阻塞在PutContext中的调用方
+		loop.notEmpty.Broadcast()
+		close(loop.notFull)
+		loop.notFull = make(chan struct{})
+	}
+	loop.guard.Unlock()
+
 	return loop.closeDone
 }
 
@@ -52,17 +148,75 @@ func (loop *EventQueue) Stop() {
 	<-loop.StopAsync()
 }
 
+// Put 将f放入队列, 队列中的任务达到maxSize时返回ErrFull
 func (loop *EventQueue) Put(f func()) error {
-	select {
-	case loop.queue <- f:
-		return nil
-	default:
-		return errors.New("queue size limit")
+	loop.guard.Lock()
+	defer loop.guard.Unlock()
+
+	if loop.stopped {
+		return ErrStopped
+	}
+	if loop.n >= loop.maxSize {
+		loop.stats.Rejected++
+		return ErrFull
+	}
+
+	loop.push(f)
+	return nil
+}
+
+// PutContext 将f放入队列, 队列中的任务达到maxSize时阻塞直到队列有空间或ctx结束
+func (loop *EventQueue) PutContext(ctx context.Context, f func()) error {
+	for {
+		loop.guard.Lock()
+		if loop.stopped {
+			loop.guard.Unlock()
+			return ErrStopped
+		}
+		if loop.n < loop.maxSize {
+			loop.push(f)
+			loop.guard.Unlock()
+			return nil
+		}
+		notFull := loop.notFull
+		loop.guard.Unlock()
+
+		select {
+		case <-notFull:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 }
 
-func (loop *EventQueue) MustPut(f func()) {
-	go func() {
-		loop.queue <- f
-	}()
+// MustPut 将f放入队列, 队列已满时扩容, 不受maxSize限制. 队列停止后f被丢弃, 返回ErrStopped
+func (loop *EventQueue) MustPut(f func()) error {
+	loop.guard.Lock()
+	defer loop.guard.Unlock()
+
+	if loop.stopped {
+		return ErrStopped
+	}
+	if loop.n >= loop.maxSize {
+		loop.stats.Overflow++
+	}
+	loop.push(f)
+	return nil
+}
+
+// Len 返回队列中等待执行的任务数量
+func (loop *EventQueue) Len() int {
+	loop.guard.Lock()
+	defer loop.guard.Unlock()
+	return loop.n
+}
+
+// Stats 返回队列的统计信息
+func (loop *EventQueue) Stats() Stats {
+	loop.guard.Lock()
+	defer loop.guard.Unlock()
+
+	s := loop.stats
+	s.Len = loop.n
+	return s
 }
@@ -1,28 +1,30 @@
 package basic_event_queue
 
 import (
+	"context"
 	"math"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/MaxnSter/gnet/iface"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewEventQueue(t *testing.T) {
-	q := NewEventQueue(100, true)
+	q := NewEventQueue(100)
 	assert.NotNil(t, q, "queue should not be nil!")
+	assert.Panics(t, func() { NewEventQueue(0) })
 }
 
 func TestEventQueue_Put(t *testing.T) {
-	q := NewEventQueue(100, true)
+	q := NewEventQueue(100)
 	wg := &sync.WaitGroup{}
 	q.Run()
+	defer q.Stop()
 
 	for i := 0; i < 100; i++ {
 		wg.Add(1)
-		q.Put(nil, func(_ iface.Context) {
+		q.Put(func() {
 			for i := 0; i < math.MaxInt16; i++ {
 			}
 			wg.Done()
@@ -32,28 +34,94 @@ func TestEventQueue_Put(t *testing.T) {
 	wg.Wait()
 }
 
-func TestEventQueue_Put2(t *testing.T) {
-	q := NewEventQueue(100, true)
+func TestEventQueue_Overflow(t *testing.T) {
+	q := NewEventQueue(4)
+
+	// 未Run时任务只会入队
+	var order []int
+	for i := 0; i < 4; i++ {
+		i := i
+		assert.NoError(t, q.Put(func() { order = append(order, i) }))
+	}
+	assert.Equal(t, ErrFull, q.Put(func() {}))
+
+	for i := 4; i < 10; i++ {
+		i := i
+		q.MustPut(func() { order = append(order, i) })
+	}
+	assert.Equal(t, Stats{Len: 10, Peak: 10, Overflow: 6, Rejected: 1}, q.Stats())
+
 	q.Run()
-	defer q.Stop()
+	q.Stop()
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, order)
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, 4, len(q.buf), "buffer shrinks after draining")
+}
+
+func TestEventQueue_Limit(t *testing.T) {
+	q := NewEventQueueWithLimit(4, 6)
+
+	// Put以maxSize为上限, 超出queueSize的部分扩容缓存
+	var order []int
+	for i := 0; i < 7; i++ {
+		i := i
+		err := q.Put(func() { order = append(order, i) })
+		if i < 6 {
+			assert.NoError(t, err)
+		} else {
+			assert.Equal(t, ErrFull, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, q.PutContext(ctx, func() {}))
 
-	q.Put(nil, func(_ iface.Context) {
-		panic("panic")
-	})
+	// MustPut不受maxSize限制
+	for i := 7; i < 9; i++ {
+		i := i
+		assert.NoError(t, q.MustPut(func() { order = append(order, i) }))
+	}
+	assert.Equal(t, Stats{Len: 8, Peak: 8, Overflow: 2, Rejected: 1}, q.Stats())
 
-	time.Sleep(time.Second * 2)
+	q.Run()
+	q.Stop()
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 7, 8}, order)
 
+	// 上限小于queueSize时使用queueSize
+	assert.Equal(t, 4, NewEventQueueWithLimit(4, 2).maxSize)
+}
+
+func TestEventQueue_PutContext(t *testing.T) {
+	q := NewEventQueue(1)
+	assert.NoError(t, q.Put(func() {}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, q.PutContext(ctx, func() {}))
+
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, q.PutContext(context.Background(), func() { close(done) }))
+	}()
+
+	q.Run()
+	defer q.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "PutContext not woken")
+	}
 }
 
 func TestEventQueue_Stop(t *testing.T) {
 
-	q := NewEventQueue(100, true)
+	q := NewEventQueue(100)
 	q.Run()
 
+	count := 0
 	for i := 0; i < 5000; i++ {
-		q.MustPut(nil, func(_ iface.Context) {
-			for i := 0; i < math.MaxUint8; i++ {
-			}
+		q.MustPut(func() {
+			count++
 		})
 	}
 
@@ -61,6 +129,11 @@ func TestEventQueue_Stop(t *testing.T) {
 	case <-time.After(10 * time.Second):
 		assert.Fail(t, "queue not stopped")
 	case <-q.StopAsync():
-
+		assert.Equal(t, 5000, count)
 	}
+
+	assert.Equal(t, ErrStopped, q.Put(func() {}))
+	assert.Equal(t, ErrStopped, q.PutContext(context.Background(), func() {}))
+	assert.Equal(t, ErrStopped, q.MustPut(func() { assert.Fail(t, "task put after stop") }))
+	q.Stop()
 }
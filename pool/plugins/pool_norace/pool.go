@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/MaxnSter/gnet/clock"
 	"github.com/MaxnSter/gnet/pool"
 )
 
@@ -13,12 +14,46 @@ const (
 	DefaultMaxGoroutineIdleDuration = 10 * time.Second
 )
 
-func New() pool.Pool {
-	return newPoolNoRace()
+var _ pool.ConfigReporter = (*poolNoRace)(nil)
+
+// Option 是poolNoRace的配置, 非正数的字段使用默认值
+type Option struct {
+	// MaxGoroutines 同时存在的goroutine数量上限, 默认为DefaultMaxGoroutinesAmount
+	MaxGoroutines int
+
+	// IdleTimeout 空闲goroutine被回收前的时间, 默认为DefaultMaxGoroutineIdleDuration
+	IdleTimeout time.Duration
+
+	// Clock 空闲goroutine回收使用的时间, 默认为clock.Real
+	Clock clock.Clock
+}
+
+func WithMaxGoroutines(n int) func(*Option) {
+	return func(o *Option) {
+		o.MaxGoroutines = n
+	}
+}
+
+func WithIdleTimeout(d time.Duration) func(*Option) {
+	return func(o *Option) {
+		o.IdleTimeout = d
+	}
+}
+
+func WithClock(c clock.Clock) func(*Option) {
+	return func(o *Option) {
+		o.Clock = c
+	}
+}
+
+func New(opts ...func(*Option)) pool.Pool {
+	return newPoolNoRace(opts...)
 }
 
 func init() {
-	pool.RegisterWorkerPool(Name, New)
+	pool.RegisterWorkerPoolWithConfig(Name, func(c pool.Config) pool.Pool {
+		return New(WithMaxGoroutines(c.MaxGoroutines), WithIdleTimeout(c.IdleTimeout))
+	})
 }
 
 type goChan struct {
@@ -30,6 +65,7 @@ type goChan struct {
 type poolNoRace struct {
 	maxGoroutinesAmount      int
 	maxGoroutineIdleDuration time.Duration
+	clock                    clock.Clock
 
 	lock            *sync.Mutex
 	goroutinesCount int
@@ -42,15 +78,31 @@ type poolNoRace struct {
 	closeDone chan struct{}
 }
 
-// TypeName返回pool的唯一表示
 func (p *poolNoRace) String() string {
 	return Name
 }
 
-func newPoolNoRace() pool.Pool {
+// Config 返回pool的配置, 见pool.ConfigReporter
+func (p *poolNoRace) Config() pool.Config {
+	return pool.Config{MaxGoroutines: p.maxGoroutinesAmount, IdleTimeout: p.maxGoroutineIdleDuration}
+}
+
+func newPoolNoRace(opts ...func(*Option)) *poolNoRace {
+	opt := Option{}
+	for _, f := range opts {
+		f(&opt)
+	}
+	if opt.MaxGoroutines <= 0 {
+		opt.MaxGoroutines = DefaultMaxGoroutinesAmount
+	}
+	if opt.IdleTimeout <= 0 {
+		opt.IdleTimeout = DefaultMaxGoroutineIdleDuration
+	}
+
 	return &poolNoRace{
-		maxGoroutinesAmount:      DefaultMaxGoroutinesAmount,
-		maxGoroutineIdleDuration: DefaultMaxGoroutineIdleDuration,
+		maxGoroutinesAmount:      opt.MaxGoroutines,
+		maxGoroutineIdleDuration: opt.IdleTimeout,
+		clock:                    clock.OrReal(opt.Clock),
 		lock:                     &sync.Mutex{},
 		ready:                    make([]*goChan, 0),
 		stopCh:                   make(chan struct{}),
@@ -72,7 +124,7 @@ func (p *poolNoRace) start() {
 			case <-p.stopCh:
 				return
 			default:
-				time.Sleep(p.maxGoroutineIdleDuration)
+				p.clock.Sleep(p.maxGoroutineIdleDuration)
 				p.clean(&scratch)
 			}
 		}
@@ -80,7 +132,7 @@ func (p *poolNoRace) start() {
 }
 
 func (p *poolNoRace) clean(scratch *[]*goChan) {
-	curTime := time.Now()
+	curTime := p.clock.Now()
 
 	p.lock.Lock()
 	ready, size, i := p.ready, len(p.ready), 0
@@ -128,7 +180,10 @@ func (p *poolNoRace) StopAsync() (done <-chan struct{}) {
 		for {
 			time.Sleep(1 * time.Second)
 
-			if p.goroutinesCount == 0 {
+			p.lock.Lock()
+			count := p.goroutinesCount
+			p.lock.Unlock()
+			if count == 0 {
 				if p.closeDone != nil {
 					close(p.closeDone)
 				}
@@ -204,7 +259,7 @@ func (p *poolNoRace) getCh() *goChan {
 	if vch == nil {
 		vch = &goChan{
 			ch:          make(chan func(), 1),
-			lastUseTime: time.Now(),
+			lastUseTime: p.clock.Now(),
 		}
 	}
 	ch = vch.(*goChan)
@@ -236,7 +291,7 @@ func (p *poolNoRace) goroutineFunc(goCh *goChan) {
 }
 
 func (p *poolNoRace) release(goCh *goChan) bool {
-	goCh.lastUseTime = time.Now()
+	goCh.lastUseTime = p.clock.Now()
 
 	p.lock.Lock()
 	if p.mustStop {
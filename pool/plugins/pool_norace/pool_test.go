@@ -6,33 +6,23 @@ import (
 	"testing"
 	"time"
 
-	"github.com/MaxnSter/gnet/iface"
+	"github.com/MaxnSter/gnet/clock"
+	"github.com/MaxnSter/gnet/pool"
 	"github.com/stretchr/testify/assert"
 )
 
-type tSession struct{ Id int64 }
-
-func (ts *tSession) ID() int64                { return ts.Id }
-func (ts *tSession) Send(message interface{}) {}
-func (ts *tSession) Stop()                    {}
-
-func (ts *tSession) Run() {
-	for i := 0; i < math.MaxInt16; i++ {
-	}
-}
-
 func TestNewPoolNoRace(t *testing.T) {
 	p := newPoolNoRace()
 	assert.NotNil(t, p, "pool should not be nil")
 
-	ts := &tSession{Id: 1}
 	wg := sync.WaitGroup{}
-	p.Start()
+	p.Run()
 
 	for i := 0; i < 500000; i++ {
 		wg.Add(1)
-		p.Put(ts, func(_ iface.Context) {
-			ts.Run()
+		p.Put(func() {
+			for i := 0; i < math.MaxInt16; i++ {
+			}
 			wg.Done()
 		})
 	}
@@ -42,65 +32,71 @@ func TestNewPoolNoRace(t *testing.T) {
 }
 
 func TestPoolNoRace_Stop(t *testing.T) {
-
 	q := newPoolNoRace()
 	wg := &sync.WaitGroup{}
-	wgDoneCh := make(chan struct{})
-	q.Start()
+	q.Run()
 
 	for i := 0; i < 10000; i++ {
 		wg.Add(1)
-		q.Put(nil, func(_ iface.Context) {
+		q.Put(func() {
 			for i := 0; i < math.MaxUint8; i++ {
 			}
 			wg.Done()
 		})
 	}
 
+	// Stop返回时所有任务已经执行完毕, 之后投放的任务不再执行
 	q.Stop()
-	wg.Add(1)
-	q.Put(nil, func(_ iface.Context) {
-		wg.Done()
-	})
-
-	go func() {
-		wg.Wait()
-		wgDoneCh <- struct{}{}
-	}()
-
-	select {
-	case <-time.After(10 * time.Second):
-	case <-wgDoneCh:
+	q.Put(func() {
 		assert.Fail(t, "queue not stopped")
-	}
+	})
+	wg.Wait()
 }
 
-func TestPoolNoRace_Stop2(t *testing.T) {
+func TestPoolNoRace_IdleClean(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	p := newPoolNoRace(WithClock(fake))
+	p.Run()
 
-	q := newPoolNoRace()
-	wg := &sync.WaitGroup{}
-	wgDoneCh := make(chan struct{})
-	q.Start()
+	// 等待清理goroutine进入Sleep, 在两次清理之间使用worker
+	fake.BlockUntil(1)
+	fake.Advance(p.maxGoroutineIdleDuration / 2)
 
-	for i := 0; i < 5; i++ {
-		wg.Add(1)
-		q.Put(nil, func(_ iface.Context) {
-			for i := 0; i < math.MaxInt8; i++ {
-			}
-			wg.Done()
-		})
+	done := make(chan struct{})
+	p.Put(func() {
+		close(done)
+	})
+	<-done
+
+	ready := func() int {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		return len(p.ready)
+	}
+	for ready() == 0 {
+		// 等待worker执行完毕后放回ready
+		time.Sleep(time.Millisecond)
 	}
 
-	q.Stop()
+	// 空闲时间不足, 不回收
+	fake.Advance(p.maxGoroutineIdleDuration / 2)
+	fake.BlockUntil(1)
+	assert.Equal(t, 1, ready())
 
-	go func() {
-		wg.Wait()
-		wgDoneCh <- struct{}{}
-	}()
+	fake.Advance(p.maxGoroutineIdleDuration)
+	fake.BlockUntil(1)
+	assert.Equal(t, 0, ready())
+}
 
-	select {
-	case <-time.After(6 * time.Second):
-		assert.Fail(t, "queue stopped before all task finished!")
-	case <-wgDoneCh:
-	}
+func TestPoolNoRace_Option(t *testing.T) {
+	p := newPoolNoRace()
+	assert.Equal(t, Name, p.String())
+	assert.Equal(t, pool.Config{MaxGoroutines: 262144, IdleTimeout: 10 * time.Second}, p.Config())
+
+	p = newPoolNoRace(WithMaxGoroutines(2), WithIdleTimeout(time.Minute))
+	assert.Equal(t, pool.Config{MaxGoroutines: 2, IdleTimeout: time.Minute}, p.Config())
+
+	r := pool.MustGetWorkerPool(Name, pool.WithMaxGoroutines(8), pool.WithQueueSize(16))
+	assert.Equal(t, Name, r.String())
+	assert.Equal(t, pool.Config{MaxGoroutines: 8, IdleTimeout: 10 * time.Second}, r.(pool.ConfigReporter).Config())
 }
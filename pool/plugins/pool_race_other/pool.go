@@ -1,36 +1,87 @@
 package pool_race_other
 
 import (
+	"context"
+
 	"github.com/MaxnSter/gnet/pool"
 	"github.com/MaxnSter/gnet/pool/plugins/internal/basic_event_queue"
 )
 
 const (
-	Name      = "poolRaceOther"
-	queueSize = 1024
+	Name             = "poolRaceOther"
+	DefaultQueueSize = 1024
 )
 
-func New() pool.Pool {
-	return newPoolRaceOther()
+// Option 是poolRaceOther的配置, 非正数的字段使用默认值
+type Option struct {
+	// QueueSize 任务队列的大小, 默认为DefaultQueueSize
+	QueueSize int
+
+	// MaxQueueSize TryPut与PutContext最多缓存的任务数量, 默认为QueueSize, Put不受限制
+	MaxQueueSize int
+}
+
+func WithQueueSize(n int) func(*Option) {
+	return func(o *Option) {
+		o.QueueSize = n
+	}
+}
+
+func WithMaxQueueSize(n int) func(*Option) {
+	return func(o *Option) {
+		o.MaxQueueSize = n
+	}
+}
+
+func New(opts ...func(*Option)) pool.Pool {
+	return newPoolRaceOther(opts...)
 }
 
 func init() {
-	pool.RegisterWorkerPool(Name, New)
+	pool.RegisterWorkerPoolWithConfig(Name, func(c pool.Config) pool.Pool {
+		return New(WithQueueSize(c.QueueSize), WithMaxQueueSize(c.MaxQueueSize))
+	})
 }
 
+var (
+	_ pool.ContextPutter  = (*poolRaceOther)(nil)
+	_ pool.StatsReporter  = (*poolRaceOther)(nil)
+	_ pool.ConfigReporter = (*poolRaceOther)(nil)
+)
+
 //single EvnetLoop,保证绝对goroutine safe,可用于无锁服务
 type poolRaceOther struct {
-	queue *basic_event_queue.EventQueue
+	queue        *basic_event_queue.EventQueue
+	queueSize    int
+	maxQueueSize int
 }
 
-// TypeName返回pool的唯一表示
 func (p *poolRaceOther) String() string {
 	return Name
 }
 
-func newPoolRaceOther() pool.Pool {
+// Config 返回pool的配置, 见pool.ConfigReporter
+func (p *poolRaceOther) Config() pool.Config {
+	return pool.Config{QueueSize: p.queueSize, MaxQueueSize: p.maxQueueSize}
+}
+
+func newPoolRaceOther(opts ...func(*Option)) *poolRaceOther {
+	opt := Option{}
+	for _, f := range opts {
+		f(&opt)
+	}
+	if opt.QueueSize <= 0 {
+		opt.QueueSize = DefaultQueueSize
+	}
+
+	if opt.MaxQueueSize > 0 && opt.MaxQueueSize < opt.QueueSize {
+		opt.MaxQueueSize = opt.QueueSize
+	}
+
 	return &poolRaceOther{
-		queue: basic_event_queue.NewEventQueue(queueSize),
+		queue:        basic_event_queue.NewEventQueueWithLimit(opt.QueueSize, opt.MaxQueueSize),
+		queueSize:    opt.QueueSize,
+		maxQueueSize: opt.MaxQueueSize,
 	}
 }
 
@@ -50,11 +101,10 @@ func (p *poolRaceOther) Stop() {
 	<-p.StopAsync()
 }
 
-// Put往pool中投放任务,无论pool是否已满,此次投放必定成功
+// Put往pool中投放任务,无论pool是否已满,此次投放必定成功.
+// 缓存的任务数量不受MaxQueueSize限制, 需要限制时使用TryPut或PutContext
 func (p *poolRaceOther) Put(f func(), opts ...func(*pool.Option)) {
-	if ok := p.TryPut(f); !ok {
-		p.queue.MustPut(f)
-	}
+	p.queue.MustPut(f)
 }
 
 // TryPut与Put相同,但当pool已满试,投放失败,返回false
@@ -66,3 +116,13 @@ func (p *poolRaceOther) TryPut(f func(), opts ...func(*pool.Option)) bool {
 
 	return true
 }
+
+// PutContext与TryPut相同,但当pool已满时阻塞直到有空间或ctx结束, 见pool.ContextPutter
+func (p *poolRaceOther) PutContext(ctx context.Context, f func(), opts ...func(*pool.Option)) error {
+	return p.queue.PutContext(ctx, f)
+}
+
+// Stats 返回任务队列的统计信息, 见pool.StatsReporter
+func (p *poolRaceOther) Stats() pool.Stats {
+	return p.queue.Stats()
+}
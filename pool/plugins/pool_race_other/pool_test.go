@@ -1,33 +1,26 @@
 package pool_race_other
 
 import (
+	"context"
 	"math"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/MaxnSter/gnet/iface"
+	"github.com/MaxnSter/gnet/pool"
 	"github.com/stretchr/testify/assert"
 )
 
 type tSession struct {
-	Id   int64
+	Id   uint64
 	race *int
 }
 
-func (ts *tSession) ID() int64                { return ts.Id }
-func (ts *tSession) Send(message interface{}) {}
-func (ts *tSession) Stop()                    {}
-
-func (ts *tSession) Run() {
-	for i := 0; i < math.MaxInt16; i++ {
-	}
-}
-
 func TestNewPoolRaceOther(t *testing.T) {
 	p := newPoolRaceOther()
 	assert.NotNil(t, p, "pool should not be nil")
-	p.Start()
+	p.Run()
+	defer p.Stop()
 
 	ts := &tSession{
 		Id:   1,
@@ -40,7 +33,7 @@ func TestNewPoolRaceOther(t *testing.T) {
 	}
 
 	wg := sync.WaitGroup{}
-	fTs := func(_ iface.Context) {
+	fTs := func() {
 
 		if ts.race != nil {
 			*ts.race = 1
@@ -50,10 +43,9 @@ func TestNewPoolRaceOther(t *testing.T) {
 		wg.Done()
 	}
 
-	fTs1 := func(_ iface.Context) {
+	fTs1 := func() {
 
 		if ts1.race != nil {
-			time.Sleep(time.Millisecond)
 			*ts1.race = 1
 		}
 		ts.race = nil
@@ -66,77 +58,128 @@ func TestNewPoolRaceOther(t *testing.T) {
 
 		//never panic
 		if i%2 == 0 {
-			p.Put(ts, fTs)
+			p.Put(fTs)
 		} else {
-			p.Put(ts, fTs1)
+			p.Put(fTs1)
 		}
-
-		//may panic
-		//if i%2 == 0 {
-		//	go fTs()
-		//} else {
-		//	go fTs1()
-		//}
-
 	}
 
 	wg.Wait()
 }
 
-func BenchmarkNewPoolRaceOther(b *testing.B) {
+func TestPoolRaceOther_Option(t *testing.T) {
+	assert.Equal(t, Name, newPoolRaceOther().String())
+	assert.Equal(t, pool.Config{QueueSize: 1024}, newPoolRaceOther().Config())
+	assert.Equal(t, pool.Config{QueueSize: 8}, newPoolRaceOther(WithQueueSize(8)).Config())
 
-	p := newPoolRaceOther()
-	p.Start()
+	r := pool.MustGetWorkerPool(Name, pool.WithQueueSize(16), pool.WithWorkers(2))
+	assert.Equal(t, Name, r.String())
+	assert.Equal(t, pool.Config{QueueSize: 16}, r.(pool.ConfigReporter).Config())
+}
 
-	ts := &tSession{
-		Id:   1,
-		race: new(int),
-	}
+func TestPoolRaceOther_Order(t *testing.T) {
+	p := newPoolRaceOther(WithQueueSize(4))
+	p.Run()
 
-	ts1 := &tSession{
-		Id:   2,
-		race: new(int),
+	// 队列已满时Put不会打乱顺序
+	var order []int
+	for i := 0; i < 1000; i++ {
+		i := i
+		p.Put(func() {
+			order = append(order, i)
+		})
 	}
+	p.Stop()
 
-	wg := sync.WaitGroup{}
-	fTs := func(_ iface.Context) {
-
-		if ts.race != nil {
-			*ts.race = 1
+	assert.Equal(t, 1000, len(order))
+	for i, v := range order {
+		if !assert.Equal(t, i, v) {
+			break
 		}
-
-		ts1.race = nil
-		wg.Done()
 	}
+}
 
-	fTs1 := func(_ iface.Context) {
+func TestPoolRaceOther_PutContext(t *testing.T) {
+	p := pool.MustGetWorkerPool(Name, pool.WithQueueSize(2))
+	p.Run()
+
+	started, block := make(chan struct{}), make(chan struct{})
+	p.Put(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	assert.True(t, p.TryPut(func() {}))
+	assert.True(t, p.TryPut(func() {}))
+	assert.False(t, p.TryPut(func() {}))
+
+	// 队列已满时PutContext等待到ctx结束
+	cp := p.(pool.ContextPutter)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, cp.PutContext(ctx, func() {}))
+
+	sr := p.(pool.StatsReporter)
+	assert.Equal(t, pool.Stats{Len: 2, Peak: 2, Rejected: 1}, sr.Stats())
+
+	// 队列有空间之后PutContext成功
+	done := make(chan error)
+	go func() {
+		done <- cp.PutContext(context.Background(), func() {})
+	}()
+	close(block)
+	assert.Nil(t, <-done)
 
-		if ts1.race != nil {
-			time.Sleep(time.Millisecond)
-			*ts1.race = 1
-		}
-		ts.race = nil
-		wg.Done()
-	}
+	p.Stop()
+	assert.Equal(t, pool.ErrStopped, cp.PutContext(context.Background(), func() {}))
+	assert.Equal(t, 0, sr.Stats().Len)
+}
 
-	for i := 0; i < 10000; i++ {
+func TestPoolRaceOther_MaxQueueSize(t *testing.T) {
+	p := pool.MustGetWorkerPool(Name, pool.WithQueueSize(2), pool.WithMaxQueueSize(3))
+	assert.Equal(t, pool.Config{QueueSize: 2, MaxQueueSize: 3}, p.(pool.ConfigReporter).Config())
+	p.Run()
+
+	started, block := make(chan struct{}), make(chan struct{})
+	p.Put(func() {
+		close(started)
+		<-block
+	})
+	<-started
+
+	// TryPut与PutContext缓存的任务达到MaxQueueSize之后投放失败
+	var count int
+	for i := 0; i < 3; i++ {
+		assert.True(t, p.TryPut(func() { count++ }))
+	}
+	assert.False(t, p.TryPut(func() { count++ }))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.(pool.ContextPutter).PutContext(ctx, func() { count++ }))
+
+	// Put不受MaxQueueSize限制, 任务不会被丢弃
+	for i := 0; i < 2; i++ {
+		p.Put(func() { count++ })
+	}
+	assert.Equal(t, pool.Stats{Len: 5, Peak: 5, Overflow: 2, Rejected: 1}, p.(pool.StatsReporter).Stats())
 
-		wg.Add(1)
+	close(block)
+	p.Stop()
+	assert.Equal(t, 5, count)
+}
 
-		//never panic
-		if i%2 == 0 {
-			p.Put(ts, fTs)
-		} else {
-			p.Put(ts, fTs1)
-		}
+func BenchmarkNewPoolRaceOther(b *testing.B) {
 
-		//MUST panic in Benchmark
-		//if i%2 == 0 {
-		//	go fTs()
-		//} else {
-		//	go fTs1()
-		//}
+	p := newPoolRaceOther()
+	p.Run()
+	defer p.Stop()
 
+	wg := sync.WaitGroup{}
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		p.Put(func() {
+			wg.Done()
+		})
 	}
 
 	wg.Wait()
@@ -145,15 +188,12 @@ func BenchmarkNewPoolRaceOther(b *testing.B) {
 func TestPoolRaceOther_Stop(t *testing.T) {
 
 	q := newPoolRaceOther()
-	wg := &sync.WaitGroup{}
-	q.Start()
+	q.Run()
 
 	for i := 0; i < 5; i++ {
-		wg.Add(1)
-		q.Put(nil, func(_ iface.Context) {
+		q.Put(func() {
 			for i := 0; i < math.MaxUint8; i++ {
 			}
-			wg.Done()
 		})
 	}
 
@@ -169,11 +209,11 @@ func TestPoolRaceOther_Stop2(t *testing.T) {
 	q := newPoolRaceOther()
 	wg := &sync.WaitGroup{}
 	wgDoneCh := make(chan struct{})
-	q.Start()
+	q.Run()
 
 	for i := 0; i < 5; i++ {
 		wg.Add(1)
-		q.Put(nil, func(_ iface.Context) {
+		q.Put(func() {
 			for i := 0; i < math.MaxInt8; i++ {
 			}
 			wg.Done()
@@ -0,0 +1,169 @@
+package pool_race_self
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Affinity 由poolRaceSelf实现, 用于控制session与worker的对应关系,
+// 可以通过类型断言从pool.Pool获得
+type Affinity interface {
+	// Pin 将session固定到第worker个worker, worker超出范围时panic.
+	// session仍有未执行完的任务时, 在这些任务执行完毕后生效.
+	// pool不知道session何时结束, session结束后需要调用Unpin, 否则Pin一直保留
+	Pin(id uint64, worker int)
+
+	// Unpin 取消Pin, session重新由一致性哈希决定worker
+	Unpin(id uint64)
+
+	// WorkerOf 返回session的下一个任务将被分配到的worker
+	WorkerOf(id uint64) int
+}
+
+var _ Affinity = (*poolRaceSelf)(nil)
+
+// routeShards 是路由表的分片数量, 不同session的Put只在分片上竞争
+const routeShards = 64
+
+// route 记录session当前的worker, 只在session有未执行完的任务时存在.
+// pending在持有分片guard时增加, 在任务结束时原子地减少
+type route struct {
+	worker  int
+	pending int32
+}
+
+// routeShard 是路由表的一个分片, session按id分配到分片
+type routeShard struct {
+	guard  sync.Mutex
+	routes map[uint64]*route
+	pins   map[uint64]int
+}
+
+func newRouteShards() []*routeShard {
+	shards := make([]*routeShard, routeShards)
+	for i := range shards {
+		shards[i] = &routeShard{routes: map[uint64]*route{}, pins: map[uint64]int{}}
+	}
+	return shards
+}
+
+func (p *poolRaceSelf) shard(id uint64) *routeShard {
+	return p.shards[mix(id)%routeShards]
+}
+
+func (p *poolRaceSelf) Pin(id uint64, worker int) {
+	if worker < 0 || worker >= len(p.workers) {
+		panic("pin session to unknown worker")
+	}
+
+	s := p.shard(id)
+	s.guard.Lock()
+	s.pins[id] = worker
+	s.guard.Unlock()
+}
+
+func (p *poolRaceSelf) Unpin(id uint64) {
+	s := p.shard(id)
+	s.guard.Lock()
+	delete(s.pins, id)
+	s.guard.Unlock()
+}
+
+func (p *poolRaceSelf) WorkerOf(id uint64) int {
+	s := p.shard(id)
+	s.guard.Lock()
+	if r, ok := s.routes[id]; ok {
+		s.guard.Unlock()
+		return r.worker
+	}
+	w, pinned := s.pins[id]
+	s.guard.Unlock()
+
+	if pinned {
+		return w
+	}
+	return p.choose(id)
+}
+
+// acquire 为session的一个新任务分配worker.
+// session有未执行完的任务时沿用其worker, 以保证顺序
+func (p *poolRaceSelf) acquire(id uint64) *route {
+	s := p.shard(id)
+	s.guard.Lock()
+	if r, ok := s.routes[id]; ok {
+		atomic.AddInt32(&r.pending, 1)
+		s.guard.Unlock()
+		return r
+	}
+	w, pinned := s.pins[id]
+	s.guard.Unlock()
+
+	// 检查worker的负载时不持有guard
+	if !pinned {
+		w = p.choose(id)
+	}
+
+	s.guard.Lock()
+	defer s.guard.Unlock()
+
+	// 期间session的其他任务可能已经分配了worker
+	r, ok := s.routes[id]
+	if !ok {
+		r = &route{worker: w}
+		s.routes[id] = r
+	}
+	atomic.AddInt32(&r.pending, 1)
+	return r
+}
+
+// release 在session的一个任务执行完毕(或投放失败)后调用,
+// 只有最后一个任务结束时才需要guard
+func (p *poolRaceSelf) release(id uint64, r *route) {
+	if atomic.AddInt32(&r.pending, -1) > 0 {
+		return
+	}
+
+	s := p.shard(id)
+	s.guard.Lock()
+	if atomic.LoadInt32(&r.pending) == 0 && s.routes[id] == r {
+		delete(s.routes, id)
+	}
+	s.guard.Unlock()
+}
+
+// choose 为没有未执行完任务且没有Pin的session选择worker
+func (p *poolRaceSelf) choose(id uint64) int {
+	w := jumpHash(mix(id), len(p.workers))
+	if p.workers[w] == nil || p.workers[w].Len() < p.overload {
+		return w
+	}
+
+	// 过载, 选择等待任务最少的worker
+	for i, q := range p.workers {
+		if q.Len() < p.workers[w].Len() {
+			w = i
+		}
+	}
+	return w
+}
+
+// mix 打散id的各个bit, session的id通常是低位集中为0的snowflake id
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// jumpHash 是Lamping与Veach的一致性哈希, worker数量从n变为n+1时只有1/(n+1)的key改变映射
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
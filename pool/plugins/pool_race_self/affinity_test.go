@@ -0,0 +1,208 @@
+package pool_race_self
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJumpHash(t *testing.T) {
+	const keys = 10000
+	moved := 0
+	for k := uint64(0); k < keys; k++ {
+		a, b := jumpHash(mix(k), 10), jumpHash(mix(k), 11)
+		assert.Equal(t, a, jumpHash(mix(k), 10))
+		if a != b {
+			assert.Equal(t, 10, b, "key may only move to the new worker")
+			moved++
+		}
+	}
+	// 期望移动1/11
+	assert.InDelta(t, keys/11, moved, keys/50)
+}
+
+func TestPoolRaceSelf_Distribution(t *testing.T) {
+	p := newPoolRaceSelf(WithWorkers(8))
+
+	// 同一毫秒之外生成的snowflake id低22位都是0
+	count := make([]int, 8)
+	for i := uint64(0); i < 8000; i++ {
+		w := p.WorkerOf(i << 22)
+		assert.Equal(t, w, p.WorkerOf(i<<22))
+		count[w]++
+	}
+	for _, c := range count {
+		assert.InDelta(t, 1000, c, 200)
+	}
+}
+
+func TestPoolRaceSelf_Pin(t *testing.T) {
+	p := newPoolRaceSelf(WithWorkers(4))
+	p.Run()
+	defer p.Stop()
+
+	ts := &tSession{Id: 42}
+	home := p.WorkerOf(ts.Id)
+	target := (home + 1) % 4
+
+	block, blocked := make(chan struct{}), make(chan struct{})
+	p.Put(func() {
+		close(blocked)
+		<-block
+	}, pool.WithIdentify(ts))
+	<-blocked
+
+	// 仍有未执行完的任务时, Pin不改变worker
+	p.Pin(ts.Id, target)
+	assert.Equal(t, home, p.WorkerOf(ts.Id))
+
+	close(block)
+	done := make(chan struct{})
+	p.Put(func() { close(done) }, pool.WithIdentify(ts))
+	<-done
+	for p.WorkerOf(ts.Id) != target {
+		time.Sleep(time.Millisecond)
+	}
+
+	p.Unpin(ts.Id)
+	assert.Equal(t, home, p.WorkerOf(ts.Id))
+	assert.Panics(t, func() { p.Pin(ts.Id, 4) })
+}
+
+func TestPoolRaceSelf_Rebalance(t *testing.T) {
+	p := newPoolRaceSelf(WithWorkers(4), WithQueueSize(16), WithOverload(8))
+	p.Run()
+	defer p.Stop()
+
+	busy := &tSession{Id: 1}
+	home := p.WorkerOf(busy.Id)
+
+	// 阻塞busy的worker, 并让其队列过载
+	block, blocked := make(chan struct{}), make(chan struct{})
+	p.Put(func() {
+		close(blocked)
+		<-block
+	}, pool.WithIdentify(busy))
+	<-blocked
+
+	var order []int
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(1)
+		assert.True(t, p.TryPut(func() {
+			order = append(order, i)
+			wg.Done()
+		}, pool.WithIdentify(busy)))
+	}
+	assert.Equal(t, home, p.WorkerOf(busy.Id), "session with pending tasks never moves")
+
+	// 与busy哈希到同一个worker的新session被分配到其他worker
+	other := &tSession{Id: 2}
+	for jumpHash(mix(other.Id), 4) != home {
+		other.Id++
+	}
+	assert.NotEqual(t, home, p.WorkerOf(other.Id))
+
+	done := make(chan struct{})
+	p.Put(func() { close(done) }, pool.WithIdentify(other))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "session not rebalanced")
+	}
+
+	close(block)
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, order)
+
+	// 过载解除后回到原来的worker
+	for p.WorkerOf(other.Id) != home {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolRaceSelf_Order(t *testing.T) {
+	p := newPoolRaceSelf(WithWorkers(2), WithQueueSize(4))
+	p.Run()
+
+	// worker已满时, 同一个session的任务仍然按投放的顺序执行
+	ts := &tSession{Id: 7}
+	var order []int
+	for i := 0; i < 1000; i++ {
+		i := i
+		p.Put(func() {
+			order = append(order, i)
+		}, pool.WithIdentify(ts))
+	}
+	p.Stop()
+
+	assert.Equal(t, 1000, len(order))
+	for i, v := range order {
+		if !assert.Equal(t, i, v) {
+			break
+		}
+	}
+}
+
+func TestPoolRaceSelf_ConcurrentSessions(t *testing.T) {
+	p := newPoolRaceSelf(WithWorkers(4), WithQueueSize(8))
+	p.Run()
+
+	// 多个session并发投放, 每个session的任务仍然按顺序执行, 结束后route全部释放
+	const sessions, tasks = 64, 200
+	orders := make([][]int, sessions)
+	var wg sync.WaitGroup
+	for s := 0; s < sessions; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			ts := &tSession{Id: uint64(s)}
+			for i := 0; i < tasks; i++ {
+				i := i
+				p.Put(func() {
+					orders[s] = append(orders[s], i)
+				}, pool.WithIdentify(ts))
+			}
+		}(s)
+	}
+	wg.Wait()
+	p.Stop()
+
+	for s, order := range orders {
+		assert.Len(t, order, tasks, "session %d", s)
+		for i, v := range order {
+			if !assert.Equal(t, i, v, "session %d", s) {
+				break
+			}
+		}
+	}
+	assert.Equal(t, 0, p.routeCount())
+}
+
+func TestPoolRaceSelf_PutAfterStop(t *testing.T) {
+	p := newPoolRaceSelf(WithWorkers(2), WithQueueSize(4))
+	p.Run()
+	p.Stop()
+
+	// 投放失败的任务不会执行, session的route随之释放
+	ts := &tSession{Id: 7}
+	p.Put(func() { assert.Fail(t, "task put after stop") }, pool.WithIdentify(ts))
+	assert.False(t, p.TryPut(func() { assert.Fail(t, "task put after stop") }, pool.WithIdentify(ts)))
+
+	assert.Equal(t, 0, p.routeCount())
+}
+
+// routeCount 返回所有分片中route的数量
+func (p *poolRaceSelf) routeCount() int {
+	n := 0
+	for _, s := range p.shards {
+		s.guard.Lock()
+		n += len(s.routes)
+		s.guard.Unlock()
+	}
+	return n
+}
@@ -1,6 +1,7 @@
 package pool_race_self
 
 import (
+	"context"
 	"math/rand"
 	"time"
 
@@ -11,23 +12,84 @@ import (
 const (
 	Name = "poolRaceSelf"
 
-	workerNum = 20
-	queueSize = 256
+	DefaultWorkers   = 20
+	DefaultQueueSize = 256
 )
 
-func New() pool.Pool {
-	return newPoolRaceSelf()
+// Option 是poolRaceSelf的配置, 非正数的字段使用默认值
+type Option struct {
+	// Workers worker的数量, 默认为DefaultWorkers
+	Workers int
+
+	// QueueSize 每个worker任务队列的大小, 默认为DefaultQueueSize
+	QueueSize int
+
+	// Overload worker队列中等待的任务达到此数量时视为过载, 默认为QueueSize的3/4.
+	// 大于QueueSize时不会重新分配session, 见poolRaceSelf
+	Overload int
+
+	// MaxQueueSize 每个worker中TryPut与PutContext最多缓存的任务数量, 默认为QueueSize, Put不受限制
+	MaxQueueSize int
+}
+
+func WithWorkers(n int) func(*Option) {
+	return func(o *Option) {
+		o.Workers = n
+	}
+}
+
+func WithQueueSize(n int) func(*Option) {
+	return func(o *Option) {
+		o.QueueSize = n
+	}
+}
+
+func WithOverload(n int) func(*Option) {
+	return func(o *Option) {
+		o.Overload = n
+	}
+}
+
+func WithMaxQueueSize(n int) func(*Option) {
+	return func(o *Option) {
+		o.MaxQueueSize = n
+	}
+}
+
+func New(opts ...func(*Option)) pool.Pool {
+	return newPoolRaceSelf(opts...)
 }
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
-	pool.RegisterWorkerPool(Name, New)
+	pool.RegisterWorkerPoolWithConfig(Name, func(c pool.Config) pool.Pool {
+		return New(WithWorkers(c.Workers), WithQueueSize(c.QueueSize), WithMaxQueueSize(c.MaxQueueSize))
+	})
 }
 
-//session存在data race现象,并且几乎没有其他session交互的情况
-//此时某个指定session的事件处理,由某个指定worker负责,worker的选择方式为round robin
+var (
+	_ pool.ContextPutter  = (*poolRaceSelf)(nil)
+	_ pool.StatsReporter  = (*poolRaceSelf)(nil)
+	_ pool.ConfigReporter = (*poolRaceSelf)(nil)
+)
+
+// poolRaceSelf 适用于session存在data race现象,并且几乎没有其他session交互的情况.
+//
+// 以pool.WithIdentify投放的任务按照session分配worker, 同一个session的任务在同一个worker中
+// 按投放的顺序串行执行:
+//   - session默认由ID的一致性哈希决定worker, 相同worker数量下映射是稳定的
+//   - Pin可以将session固定到指定的worker
+//   - session的worker过载时, 将session分配到最空闲的worker
+//
+// 重新分配只发生在session没有未执行完的任务时, 因此不会破坏同一个session的任务顺序.
+// 没有指定session的任务随机分配worker
 type poolRaceSelf struct {
-	workers []*basic_event_queue.EventQueue
+	workers      []*basic_event_queue.EventQueue
+	queueSize    int
+	maxQueueSize int
+	overload     int
+
+	shards []*routeShard //按session分片的路由表, 见affinity.go
 
 	closeDone chan struct{}
 }
@@ -36,17 +98,43 @@ func (p *poolRaceSelf) String() string {
 	return Name
 }
 
-func newPoolRaceSelf() pool.Pool {
+// Config 返回pool的配置, 见pool.ConfigReporter
+func (p *poolRaceSelf) Config() pool.Config {
+	return pool.Config{Workers: len(p.workers), QueueSize: p.queueSize, MaxQueueSize: p.maxQueueSize}
+}
+
+func newPoolRaceSelf(opts ...func(*Option)) *poolRaceSelf {
+	opt := Option{}
+	for _, f := range opts {
+		f(&opt)
+	}
+	if opt.Workers <= 0 {
+		opt.Workers = DefaultWorkers
+	}
+	if opt.QueueSize <= 0 {
+		opt.QueueSize = DefaultQueueSize
+	}
+	if opt.MaxQueueSize > 0 && opt.MaxQueueSize < opt.QueueSize {
+		opt.MaxQueueSize = opt.QueueSize
+	}
+	if opt.Overload <= 0 {
+		opt.Overload = opt.QueueSize * 3 / 4
+	}
+
 	return &poolRaceSelf{
-		workers:   make([]*basic_event_queue.EventQueue, workerNum),
-		closeDone: make(chan struct{}),
+		workers:      make([]*basic_event_queue.EventQueue, opt.Workers),
+		queueSize:    opt.QueueSize,
+		maxQueueSize: opt.MaxQueueSize,
+		overload:     opt.Overload,
+		shards:       newRouteShards(),
+		closeDone:    make(chan struct{}),
 	}
 }
 
 // Start启动pool,此方法保证goroutineeeee safe
 func (p *poolRaceSelf) Run() {
 	for i := range p.workers {
-		p.workers[i] = basic_event_queue.NewEventQueue(queueSize)
+		p.workers[i] = basic_event_queue.NewEventQueueWithLimit(p.queueSize, p.maxQueueSize)
 	}
 
 	for _, w := range p.workers {
@@ -62,7 +150,7 @@ func (p *poolRaceSelf) Stop() {
 
 // StopAsync与Stop相同,但它立即返回, pool完全停止时done active
 func (p *poolRaceSelf) StopAsync() (done <-chan struct{}) {
-	chans := make([]<-chan struct{}, 0, workerNum)
+	chans := make([]<-chan struct{}, 0, len(p.workers))
 
 	for _, w := range p.workers {
 		chans = append(chans, w.StopAsync())
@@ -81,41 +169,76 @@ func (p *poolRaceSelf) StopAsync() (done <-chan struct{}) {
 	return p.closeDone
 }
 
-// Put往pool中投放任务,无论pool是否已满,此次投放必定成功
+// Put往pool中投放任务,无论pool是否已满,此次投放必定成功.
+// worker已满时任务按顺序缓存在worker的队列中, 不会打乱session的任务顺序.
+// 缓存的任务数量不受MaxQueueSize限制, 需要限制时使用TryPut或PutContext
 func (p *poolRaceSelf) Put(f func(), opts ...func(*pool.Option)) {
-	var w *basic_event_queue.EventQueue
-	o := &pool.Option{}
-	for _, f := range opts {
-		f(o)
+	w, f, refused := p.dispatch(f, opts...)
+	if w.MustPut(f) != nil {
+		refused()
 	}
+}
 
-	if o.Identifier != nil {
-		w = p.workers[o.Identifier.ID()%workerNum]
-	} else {
-		w = p.workers[rand.Intn(workerNum)%workerNum]
+// TryPut与Put相同,但当pool已满试,投放失败,返回false
+func (p *poolRaceSelf) TryPut(f func(), opts ...func(*pool.Option)) bool {
+	w, f, refused := p.dispatch(f, opts...)
+	if w.Put(f) != nil {
+		refused()
+		return false
 	}
+	return true
+}
 
-	if err := w.Put(f); err != nil {
-		w.MustPut(f)
+// PutContext与TryPut相同,但当worker已满时阻塞直到有空间或ctx结束, 见pool.ContextPutter
+func (p *poolRaceSelf) PutContext(ctx context.Context, f func(), opts ...func(*pool.Option)) error {
+	w, f, refused := p.dispatch(f, opts...)
+	if err := w.PutContext(ctx, f); err != nil {
+		refused()
+		return err
 	}
+	return nil
 }
 
-// TryPut与Put相同,但当pool已满试,投放失败,返回false
-func (p *poolRaceSelf) TryPut(f func(), opts ...func(*pool.Option)) bool {
-	var w *basic_event_queue.EventQueue
+// Stats 返回所有worker任务队列的统计信息之和, Peak为单个worker的最大值, 见pool.StatsReporter
+func (p *poolRaceSelf) Stats() pool.Stats {
+	s := pool.Stats{}
+	for _, w := range p.workers {
+		if w == nil {
+			// pool还未Run
+			continue
+		}
+		ws := w.Stats()
+		s.Len += ws.Len
+		s.Overflow += ws.Overflow
+		s.Rejected += ws.Rejected
+		if ws.Peak > s.Peak {
+			s.Peak = ws.Peak
+		}
+	}
+	return s
+}
+
+// dispatch 选择执行f的worker, 指定了session时返回包装后的f.
+// 任务投放失败(不会执行)时, 调用方需要调用refused
+func (p *poolRaceSelf) dispatch(f func(), opts ...func(*pool.Option)) (w *basic_event_queue.EventQueue, task func(), refused func()) {
 	o := &pool.Option{}
 	for _, f := range opts {
 		f(o)
 	}
 
-	if o.Identifier != nil {
-		w = p.workers[o.Identifier.ID()%workerNum]
-	} else {
-		w = p.workers[rand.Intn(workerNum)%workerNum]
+	if o.Identifier == nil {
+		return p.workers[rand.Intn(len(p.workers))], f, func() {}
 	}
 
-	if err := w.Put(f); err != nil {
-		return false
+	id := o.Identifier.ID()
+	r := p.acquire(id)
+	return p.workers[r.worker], p.wrap(id, r, f), func() { p.release(id, r) }
+}
+
+// wrap 在f执行完毕后释放session的一个未执行任务
+func (p *poolRaceSelf) wrap(id uint64, r *route, f func()) func() {
+	return func() {
+		defer p.release(id, r)
+		f()
 	}
-	return true
 }
@@ -1,36 +1,35 @@
 package pool_race_self
 
 import (
+	"context"
 	"math"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/MaxnSter/gnet/iface"
-	"github.com/MaxnSter/gnet/logger"
+	"github.com/MaxnSter/gnet/pool"
 	"github.com/stretchr/testify/assert"
 )
 
 type tSession struct {
-	Id   int64
+	Id   uint64
 	race *int
 }
 
-func (ts *tSession) ID() int64                { return ts.Id }
-func (ts *tSession) Send(message interface{}) {}
-func (ts *tSession) Stop()                    {}
+func (ts *tSession) ID() uint64 { return ts.Id }
 
 func TestNewPoolRaceSelf(t *testing.T) {
 	p := newPoolRaceSelf()
 	assert.NotNil(t, p, "pool should not be nil")
-	p.Start()
+	p.Run()
+	defer p.Stop()
 
 	wg := sync.WaitGroup{}
 	raceFunc := func(sId int) {
 		nwg := sync.WaitGroup{}
-		ts := &tSession{Id: int64(sId), race: new(int)}
+		ts := &tSession{Id: uint64(sId), race: new(int)}
 
-		raceF := func(ctx iface.Context) {
+		raceF := func() {
 			if ts.race != nil {
 				time.Sleep(time.Millisecond)
 				*ts.race = 1
@@ -38,7 +37,7 @@ func TestNewPoolRaceSelf(t *testing.T) {
 			nwg.Done()
 		}
 
-		raceF1 := func(ctx iface.Context) {
+		raceF1 := func() {
 			ts.race = nil
 			nwg.Done()
 		}
@@ -46,20 +45,12 @@ func TestNewPoolRaceSelf(t *testing.T) {
 		for i := 0; i < 500; i++ {
 			nwg.Add(1)
 
-			//may panic
-			//if i%2 == 0 {
-			//	go raceF()
-			//} else {
-			//	go raceF1()
-			//}
-
 			//never panic
 			if i%2 == 0 {
-				p.Put(ts, raceF)
+				p.Put(raceF, pool.WithIdentify(ts))
 			} else {
-				p.Put(ts, raceF1)
+				p.Put(raceF1, pool.WithIdentify(ts))
 			}
-
 		}
 
 		nwg.Wait()
@@ -74,18 +65,30 @@ func TestNewPoolRaceSelf(t *testing.T) {
 	wg.Wait()
 }
 
+func TestPoolRaceSelf_Option(t *testing.T) {
+	p := newPoolRaceSelf()
+	assert.Equal(t, DefaultWorkers, len(p.workers))
+	assert.Equal(t, Name, p.String())
+	assert.Equal(t, pool.Config{Workers: 20, QueueSize: 256}, p.Config())
+
+	p = newPoolRaceSelf(WithWorkers(4), WithQueueSize(8))
+	assert.Equal(t, pool.Config{Workers: 4, QueueSize: 8}, p.Config())
+
+	r := pool.MustGetWorkerPool(Name, pool.WithWorkers(2), pool.WithMaxGoroutines(10))
+	assert.Equal(t, Name, r.String())
+	assert.Equal(t, pool.Config{Workers: 2, QueueSize: 256}, r.(pool.ConfigReporter).Config())
+}
+
 func TestPoolRaceSelf_Stop(t *testing.T) {
 
 	q := newPoolRaceSelf()
-	q.Start()
+	q.Run()
 
 	for i := 0; i < 10; i++ {
-		idx := i
-		q.Put(&tSession{int64(idx), nil}, func(ctx iface.Context) {
+		q.Put(func() {
 			for i := 0; i < math.MaxUint8; i++ {
 			}
-			logger.WithField("i", idx).Infoln("task done")
-		})
+		}, pool.WithIdentify(&tSession{Id: uint64(i)}))
 	}
 
 	select {
@@ -97,19 +100,18 @@ func TestPoolRaceSelf_Stop(t *testing.T) {
 
 func TestPoolRaceSelf_Stop2(t *testing.T) {
 
-	q := newPoolRaceSelf()
+	q := newPoolRaceSelf(WithWorkers(4), WithQueueSize(16))
 	wg := &sync.WaitGroup{}
 	wgDoneCh := make(chan struct{})
-	q.Start()
+	q.Run()
 
 	for i := 0; i < 5000; i++ {
 		wg.Add(1)
-		idx := i
-		q.Put(&tSession{int64(idx), nil}, func(_ iface.Context) {
+		q.Put(func() {
 			for i := 0; i < math.MaxInt16; i++ {
 			}
 			wg.Done()
-		})
+		}, pool.WithIdentify(&tSession{Id: uint64(i)}))
 	}
 
 	q.Stop()
@@ -125,3 +127,83 @@ func TestPoolRaceSelf_Stop2(t *testing.T) {
 	case <-wgDoneCh:
 	}
 }
+
+func TestPoolRaceSelf_PutContext(t *testing.T) {
+	p := pool.MustGetWorkerPool(Name, pool.WithWorkers(2), pool.WithQueueSize(2))
+	p.Run()
+
+	ts := &tSession{Id: 7}
+	started, block := make(chan struct{}), make(chan struct{})
+	p.Put(func() {
+		close(started)
+		<-block
+	}, pool.WithIdentify(ts))
+	<-started
+	assert.True(t, p.TryPut(func() {}, pool.WithIdentify(ts)))
+	assert.True(t, p.TryPut(func() {}, pool.WithIdentify(ts)))
+
+	// session的worker已满时PutContext等待到ctx结束
+	cp := p.(pool.ContextPutter)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, cp.PutContext(ctx, func() {}, pool.WithIdentify(ts)))
+
+	sr := p.(pool.StatsReporter)
+	assert.Equal(t, pool.Stats{Len: 2, Peak: 2}, sr.Stats())
+
+	done := make(chan error)
+	go func() {
+		done <- cp.PutContext(context.Background(), func() {}, pool.WithIdentify(ts))
+	}()
+	close(block)
+	assert.Nil(t, <-done)
+
+	p.Stop()
+	assert.Equal(t, pool.ErrStopped, cp.PutContext(context.Background(), func() {}, pool.WithIdentify(ts)))
+	assert.Equal(t, 0, sr.Stats().Len)
+
+	// 投放失败的任务不会残留在session的route中
+	rs := p.(*poolRaceSelf)
+	assert.Equal(t, 0, rs.routeCount())
+}
+
+func TestPoolRaceSelf_MaxQueueSize(t *testing.T) {
+	p := pool.MustGetWorkerPool(Name, pool.WithWorkers(2), pool.WithQueueSize(2), pool.WithMaxQueueSize(3))
+	assert.Equal(t, pool.Config{Workers: 2, QueueSize: 2, MaxQueueSize: 3}, p.(pool.ConfigReporter).Config())
+	p.Run()
+
+	ts := &tSession{Id: 7}
+	started, block := make(chan struct{}), make(chan struct{})
+	p.Put(func() {
+		close(started)
+		<-block
+	}, pool.WithIdentify(ts))
+	<-started
+
+	// session的worker缓存的任务达到MaxQueueSize之后, TryPut与PutContext投放失败
+	var order []int
+	for i := 0; i < 3; i++ {
+		i := i
+		assert.True(t, p.TryPut(func() { order = append(order, i) }, pool.WithIdentify(ts)))
+	}
+	assert.False(t, p.TryPut(func() { order = append(order, -1) }, pool.WithIdentify(ts)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded,
+		p.(pool.ContextPutter).PutContext(ctx, func() { order = append(order, -1) }, pool.WithIdentify(ts)))
+
+	// Put不受MaxQueueSize限制, 任务按顺序缓存, 不会被丢弃
+	for i := 3; i < 5; i++ {
+		i := i
+		p.Put(func() { order = append(order, i) }, pool.WithIdentify(ts))
+	}
+	assert.Equal(t, pool.Stats{Len: 5, Peak: 5, Overflow: 2, Rejected: 1}, p.(pool.StatsReporter).Stats())
+
+	close(block)
+	p.Stop()
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+
+	// 投放失败的任务不会残留在session的route中
+	rs := p.(*poolRaceSelf)
+	assert.Equal(t, 0, rs.routeCount())
+}
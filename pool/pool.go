@@ -1,5 +1,13 @@
 package pool
 
+import (
+	"context"
+	"errors"
+)
+
+// ErrStopped 是pool停止之后投放任务返回的错误
+var ErrStopped = errors.New("pool stopped")
+
 // Pool 是一个goroutine pool,
 // 单独使用可用于限制并发数量,
 // 作为gnet组件时还可以指定并发模型
@@ -9,7 +17,8 @@ type Pool interface {
 	// pool保证此时剩余的pool item全部执行完毕才返回
 	Stop()
 
-	// Put往pool中投放任务,无论pool是否已满,此次投放必定成功
+	// Put往pool中投放任务,无论pool是否已满,此次投放必定成功, 缓存的任务数量不受限制.
+	// 需要限制缓存的任务数量时使用TryPut或ContextPutter, 见Config.MaxQueueSize
 	Put(f func(), opts ...func(*Option))
 	// TryPut与Put相同,但当pool已满试,投放失败,返回false
 	TryPut(f func(), opts ...func(*Option)) bool
@@ -30,3 +39,36 @@ func WithIdentify(i Identifier) func(*Option) {
 		option.Identifier = i
 	}
 }
+
+// Stats 是pool中任务队列的统计信息, 见StatsReporter
+type Stats struct {
+	// Len 当前等待执行的任务数量
+	Len int
+
+	// Peak 单个队列中等待执行的任务数量的历史最大值
+	Peak int
+
+	// Overflow 队列已满时仍然被Put接受的任务数量
+	Overflow uint64
+
+	// Rejected 队列已满导致投放失败的次数
+	Rejected uint64
+}
+
+// ContextPutter 由可以等待队列空间的pool实现, 可以通过类型断言从Pool获得
+type ContextPutter interface {
+	// PutContext 与TryPut相同, 但pool已满时阻塞直到有空间或ctx结束.
+	// pool停止之后返回ErrStopped, ctx结束时返回ctx.Err()
+	PutContext(ctx context.Context, f func(), opts ...func(*Option)) error
+}
+
+// ConfigReporter 由可以报告自身配置的pool实现, 可以通过类型断言从Pool获得,
+// 返回的Config中只有该pool使用的字段, 未设置的字段为pool的默认值
+type ConfigReporter interface {
+	Config() Config
+}
+
+// StatsReporter 由可以报告队列统计信息的pool实现, 可以通过类型断言从Pool获得
+type StatsReporter interface {
+	Stats() Stats
+}
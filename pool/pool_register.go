@@ -1,14 +1,82 @@
 package pool
 
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config 是通过注册表创建pool时的通用配置.
+// 为0的字段使用pool自己的默认值, pool不支持的字段被忽略.
+// Config不直接用于配置文件, 配置文件见config包
+type Config struct {
+	// Workers worker的数量, 用于poolRaceSelf
+	Workers int
+
+	// QueueSize 每个worker任务队列的大小, 用于poolRaceSelf与poolRaceOther
+	QueueSize int
+
+	// MaxQueueSize TryPut与PutContext最多缓存的任务数量, 为0时等于QueueSize, Put不受限制,
+	// 用于poolRaceSelf与poolRaceOther
+	MaxQueueSize int
+
+	// MaxGoroutines 同时存在的goroutine数量上限, 用于poolNoRace
+	MaxGoroutines int
+
+	// IdleTimeout 空闲goroutine被回收前的时间, 用于poolNoRace
+	IdleTimeout time.Duration
+}
+
+func WithWorkers(n int) func(*Config) {
+	return func(c *Config) {
+		c.Workers = n
+	}
+}
+
+func WithQueueSize(n int) func(*Config) {
+	return func(c *Config) {
+		c.QueueSize = n
+	}
+}
+
+func WithMaxQueueSize(n int) func(*Config) {
+	return func(c *Config) {
+		c.MaxQueueSize = n
+	}
+}
+
+func WithMaxGoroutines(n int) func(*Config) {
+	return func(c *Config) {
+		c.MaxGoroutines = n
+	}
+}
+
+func WithIdleTimeout(d time.Duration) func(*Config) {
+	return func(c *Config) {
+		c.IdleTimeout = d
+	}
+}
+
 type workerPoolCreator func() Pool
 
+type workerPoolConfigCreator func(c Config) Pool
+
 var (
-	workerPools = map[string]workerPoolCreator{}
+	workerPools = map[string]workerPoolConfigCreator{}
 )
 
-// RegisterWorkerPool 注册一个pool.
+// RegisterWorkerPool 注册一个pool, 通过注册表创建时Config被忽略.
 // 如果name已存在,则panic
 func RegisterWorkerPool(name string, creator workerPoolCreator) {
+	RegisterWorkerPoolWithConfig(name, func(Config) Pool {
+		return creator()
+	})
+}
+
+// RegisterWorkerPoolWithConfig 注册一个根据Config创建的pool.
+// 如果name已存在,则panic
+func RegisterWorkerPoolWithConfig(name string, creator workerPoolConfigCreator) {
 	if _, ok := workerPools[name]; ok {
 		panic("dup register Pool, name : " + name)
 	}
@@ -16,12 +84,36 @@ func RegisterWorkerPool(name string, creator workerPoolCreator) {
 	workerPools[name] = creator
 }
 
-// MustGetPacker 获取指定名字对应的pool.
+// MustGetWorkerPool 以opts描述的配置创建一个指定名字对应的pool.
 // 若未注册,则panic
-func MustGetWorkerPool(name string) Pool {
-	if creator, ok := workerPools[name]; !ok {
-		panic("Pool not register, name : " + name)
-	} else {
-		return creator()
+func MustGetWorkerPool(name string, opts ...func(*Config)) Pool {
+	p, err := GetWorkerPool(name, opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return p
+}
+
+// GetWorkerPool 以opts描述的配置创建一个指定名字对应的pool.
+// 若未注册,返回错误
+func GetWorkerPool(name string, opts ...func(*Config)) (Pool, error) {
+	if creator, ok := workerPools[name]; ok {
+		c := Config{}
+		for _, f := range opts {
+			f(&c)
+		}
+		return creator(c), nil
+	}
+
+	return nil, errors.New("Pool not register, name : " + name)
+}
+
+// ListWorkerPools 返回所有已注册的pool名称,按名称排序
+func ListWorkerPools() []string {
+	names := make([]string, 0, len(workerPools))
+	for name := range workerPools {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,60 @@
+package pool_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/MaxnSter/gnet/pool/plugins/pool_race_other"
+	"github.com/MaxnSter/gnet/pool/plugins/pool_race_self"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWorkerPool(t *testing.T) {
+	names := pool.ListWorkerPools()
+	assert.Subset(t, names, []string{pool_race_other.Name, pool_race_self.Name})
+	assert.True(t, sort.StringsAreSorted(names))
+
+	// 未设置的字段使用pool的默认值
+	p, err := pool.GetWorkerPool(pool_race_other.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, pool_race_other.Name, p.String())
+	assert.Equal(t, pool.Config{QueueSize: 1024}, p.(pool.ConfigReporter).Config())
+
+	p, err = pool.GetWorkerPool(pool_race_self.Name, pool.WithWorkers(3), pool.WithQueueSize(8))
+	assert.Nil(t, err)
+	assert.Equal(t, pool.Config{Workers: 3, QueueSize: 8}, p.(pool.ConfigReporter).Config())
+
+	_, err = pool.GetWorkerPool("not_exist")
+	assert.NotNil(t, err)
+}
+
+type testPool struct {
+	pool.Pool
+}
+
+func (p testPool) String() string {
+	return "testPool"
+}
+
+// testPools 记录已注册的testPool数量, 注册无法撤销, 每次运行使用不同的名称(go test -count=n)
+var testPools int
+
+func TestRegisterWorkerPool(t *testing.T) {
+	testPools++
+	name := fmt.Sprintf("testPool%d", testPools)
+	pool.RegisterWorkerPool(name, func() pool.Pool {
+		return testPool{}
+	})
+
+	p, err := pool.GetWorkerPool(name, pool.WithWorkers(3))
+	assert.Nil(t, err)
+	assert.Equal(t, "testPool", p.String())
+
+	assert.Panics(t, func() {
+		pool.RegisterWorkerPoolWithConfig(name, func(pool.Config) pool.Pool {
+			return testPool{}
+		})
+	})
+}
@@ -20,15 +20,16 @@ type server struct {
 	Module
 	operator Operator
 
-	guard    sync.Mutex
-	sessions map[uint64]NetSession
+	guard       sync.Mutex
+	sessions    map[uint64]NetSession
+	maxSessions int
 
 	wg   sync.WaitGroup
 	once sync.Once
 	done chan struct{}
 }
 
-func NewServer(l net.Listener, m Module, o Operator) NetServer {
+func NewServer(l net.Listener, m Module, o Operator, opts ...func(NetServer)) NetServer {
 	s := &server{
 		Listener: l,
 		Module:   m,
@@ -36,9 +37,21 @@ func NewServer(l net.Listener, m Module, o Operator) NetServer {
 		sessions: map[uint64]NetSession{},
 		done:     make(chan struct{}),
 	}
+
+	for _, f := range opts {
+		f(s)
+	}
 	return s
 }
 
+// WithMaxSessions 限制server同时存在的session数量, 超出时新的连接被直接关闭.
+// n为0时不限制
+func WithMaxSessions(n int) func(NetServer) {
+	return func(s NetServer) {
+		s.(*server).maxSessions = n
+	}
+}
+
 func (svc *server) Broadcast(f func(session NetSession)) {
 	svc.guard.Lock()
 	snapshot := svc.sessions
@@ -63,11 +76,11 @@ func (svc *server) Run() {
 	svc.once.Do(func() {
 		go svc.signal()
 
-		svc.Module.Pool().Run()
+		runModule(svc.Module)
 		svc.serve()
 
 		svc.wg.Wait()
-		svc.Module.Pool().Stop()
+		stopModule(svc.Module)
 	})
 }
 
@@ -121,10 +134,16 @@ func (svc *server) serve() {
 }
 
 func (svc *server) onNewSession(conn net.Conn) {
-	id := util.GetUUID()
-	session := newSession(id, conn, svc, svc.operator)
-
+	// 先检查session数量, 超出限制的连接不再创建session
 	svc.guard.Lock()
+	if svc.maxSessions > 0 && len(svc.sessions) >= svc.maxSessions {
+		svc.guard.Unlock()
+		glog.Warningf("too many sessions(%d), close connection from %s", svc.maxSessions, conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+	id := util.GetUUID()
+	session := newSession(id, conn, svc, svc.Module, svc.operator, false)
 	svc.sessions[id] = session
 	svc.guard.Unlock()
 
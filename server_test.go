@@ -0,0 +1,70 @@
+package gnet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	"github.com/MaxnSter/gnet/pool/plugins/pool_race_other"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Limits(t *testing.T) {
+	const idle = 200 * time.Millisecond
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	started, stopped := make(chan NetSession, 4), make(chan NetSession, 4)
+	m := NewModule(pool_race_other.New(), codec.MustGetCoder("json"), packer_length_value.New())
+	o := NewOperator(m, Callback{
+		OnSession:     func(s NetSession) { started <- s },
+		OnSessionStop: func(s NetSession) { stopped <- s },
+	}, WithTimeouts(Timeouts{Idle: idle}))
+	svc := NewServer(l, m, o, WithMaxSessions(1))
+
+	done := make(chan struct{})
+	go func() {
+		svc.Run()
+		close(done)
+	}()
+	defer func() {
+		svc.Stop()
+		<-done
+	}()
+
+	// session的idle计时在连接建立之后开始, 早于Dial返回
+	begin := time.Now()
+	c1, err := net.Dial("tcp", l.Addr().String())
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer c1.Close()
+	<-started
+
+	// 超出WithMaxSessions的连接被直接关闭, 不会产生session
+	c2, err := net.Dial("tcp", l.Addr().String())
+	if assert.Nil(t, err) {
+		c2.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = c2.Read(make([]byte, 1))
+		assert.Equal(t, io.EOF, err)
+		c2.Close()
+	}
+	assert.Len(t, started, 0)
+
+	// 没有收到任何消息的session在idle之后被关闭
+	select {
+	case <-stopped:
+		assert.True(t, time.Since(begin) >= idle)
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle session not closed")
+	}
+	c1.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = c1.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
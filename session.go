@@ -10,6 +10,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/MaxnSter/gnet/clock"
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/MaxnSter/gnet/timer"
 	"github.com/MaxnSter/gnet/util"
 )
 
@@ -20,15 +25,26 @@ type session struct {
 	raw      net.Conn
 	wrQueue  *util.MsgQueue
 
-	once    sync.Once
-	closeCh chan struct{}
-	grace   time.Duration
+	once             sync.Once
+	closeCh          chan struct{}
+	grace            time.Duration
+	handshakeTimeout time.Duration
+	idleTimeout      time.Duration
 
 	guard    sync.Mutex
 	priority map[string]interface{}
+	coders   map[codec.Coder]codec.Coder
+	timers   map[uint64]timer.Cancel
+	timerSeq uint64
 
 	manager  SessionManager
 	operator Operator
+	pool     pool.Pool
+	timer    timer.Timer
+	clock    clock.Clock
+	packer   packer.Packer
+	coder    codec.Coder
+	client   bool
 }
 
 func (s *session) ID() uint64 {
@@ -57,6 +73,35 @@ func (s *session) AccessManager() SessionManager {
 	return s.manager
 }
 
+func (s *session) Packer() packer.Packer {
+	return s.packer
+}
+
+func (s *session) Coder() codec.Coder {
+	return s.coder
+}
+
+// sessionCoder 返回原型c在本session中的实例, 见codec.SessionCoder.
+// meta绑定的coder(见meta.BindCoder)同样是原型,第一次使用时为本session创建实例
+func (s *session) sessionCoder(c codec.Coder) codec.Coder {
+	if _, ok := c.(codec.SessionCoder); !ok {
+		return c
+	}
+
+	s.guard.Lock()
+	defer s.guard.Unlock()
+
+	if s.coders == nil {
+		s.coders = map[codec.Coder]codec.Coder{}
+	}
+	sc, ok := s.coders[c]
+	if !ok {
+		sc = codec.NewSession(c)
+		s.coders[c] = sc
+	}
+	return sc
+}
+
 func (s *session) Stop() {
 	select {
 	case <-s.closeCh:
@@ -66,14 +111,16 @@ func (s *session) Stop() {
 
 	s.once.Do(func() {
 		close(s.closeCh)
+		// 在关闭连接之前取消定时器, 此时Run还未返回, module的timer仍在运行
+		s.stopTimers()
 		s.raw.SetDeadline(time.Now().Add(s.grace))
 		s.raw.Close()
 	})
 }
 
 func newSession(identify uint64, conn net.Conn, manager SessionManager,
-	o Operator) NetSession {
-	return &session{
+	m Module, o Operator, client bool) NetSession {
+	s := &session{
 		identify: identify,
 		rd:       bufio.NewReader(conn),
 		wr:       bufio.NewWriter(conn),
@@ -84,18 +131,53 @@ func newSession(identify uint64, conn net.Conn, manager SessionManager,
 		priority: map[string]interface{}{},
 		manager:  manager,
 		operator: o,
+		pool:     m.Pool(),
+		timer:    m.Timer(),
+		clock:    m.Clock(),
+		packer:   packer.NewSession(m.Packer()),
+		coder:    codec.NewSession(m.Coder()),
+		client:   client,
+
+		handshakeTimeout: time.Second * 10,
 	}
+
+	if ow, ok := o.(*operatorWrapper); ok {
+		t := ow.timeouts
+		if t.Handshake > 0 {
+			s.handshakeTimeout = t.Handshake
+		}
+		if t.StopGrace > 0 {
+			s.grace = t.StopGrace
+		}
+		s.idleTimeout = t.Idle
+	}
+	return s
 }
 
 // Run start session util session.close called
 func (s *session) Run() {
-	wg := sync.WaitGroup{}
-	wg.Add(2)
+	// 协商在OnSession之前完成,OnSession中即可看到协商结果.
+	// 协商失败时session从未对上层可见, 不回调OnSession与OnSessionStop
+	if err := s.negotiate(); err != nil {
+		glog.Errorf("%+v", err)
+		s.Stop()
+		return
+	}
 
 	if cb := s.operator.GetCallback().OnSession; cb != nil {
 		cb(s)
 	}
 
+	// 握手失败时OnSession已经回调, 与之对应地回调OnSessionStop
+	if err := s.handshake(); err != nil {
+		glog.Errorf("%+v", err)
+		s.Stop()
+		s.onStop()
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
 	go func() {
 		s.readLoop()
 		wg.Done()
@@ -106,16 +188,40 @@ func (s *session) Run() {
 	}()
 
 	wg.Wait()
+	s.onStop()
+}
 
+func (s *session) onStop() {
 	if cb := s.operator.GetCallback().OnSessionStop; cb != nil {
 		cb(s)
 	}
 }
 
+// handshake 若packer实现了packer.Handshaker,在读写循环开始前与对端握手
+func (s *session) handshake() error {
+	h, ok := s.packer.(packer.Handshaker)
+	if !ok {
+		return nil
+	}
+
+	s.raw.SetDeadline(time.Now().Add(s.handshakeTimeout))
+	defer s.raw.SetDeadline(time.Time{})
+
+	// 此时writeLoop还未开始,直接写入raw conn,避免双方都阻塞在读对端的握手数据上
+	if err := h.Handshake(s.rd, s.raw); err != nil {
+		return errors.Wrap(err, "handshake failed")
+	}
+	return nil
+}
+
 func (s *session) readLoop() {
 	readF := func() error {
 		for {
-			msg, err := s.operator.Read(s.rd)
+			if s.idleTimeout > 0 {
+				s.raw.SetReadDeadline(time.Now().Add(s.idleTimeout))
+			}
+
+			msg, h, err := s.operator.Read(s, s.rd)
 			if err != nil {
 				if err == io.EOF {
 					return nil
@@ -127,12 +233,23 @@ func (s *session) readLoop() {
 						return nil
 					default:
 					}
+
+					// 空闲超时是正常的关闭, 见Timeouts.Idle
+					if s.idleTimeout > 0 {
+						glog.Infof("session %d idle for %s, close", s.ID(), s.idleTimeout)
+						return nil
+					}
+				}
+
+				// 对端违反协议(如帧校验失败),直接关闭session
+				if packer.IsProtocolError(err) {
+					return errors.Wrap(err, "protocol error, close session")
 				}
 
 				return errors.Wrap(err, "read failed")
 			}
 
-			s.operator.PostEvent(&eventWrapper{eventSession: s, msg: msg})
+			s.operator.PostEvent(&eventWrapper{eventSession: s, msg: msg, header: h})
 		}
 	}
 
@@ -158,7 +275,12 @@ func (s *session) writeLoop() {
 			}
 
 			for i := 0; i < len(items); i++ {
-				err := s.operator.Write(s.wr, items[i])
+				err := s.operator.Write(s, s.wr, items[i])
+				if _, ok := err.(*MsgError); ok {
+					// 单条消息出错不影响连接,丢弃该消息
+					glog.Errorf("session %d drop message: %v", s.ID(), err)
+					continue
+				}
 				if err != nil {
 					s.wr.Flush()
 					return errors.Wrap(err, "write failed")
@@ -0,0 +1,87 @@
+package gnet
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+type failHandshakePacker struct {
+	packer.Packer
+}
+
+func (p failHandshakePacker) Handshake(r io.Reader, w io.Writer) error {
+	return errors.New("handshake refused")
+}
+
+func runSession(t *testing.T, s *session) {
+	done := make(chan struct{})
+	go func() {
+		s.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+}
+
+func TestSession_RunNegotiateFailed(t *testing.T) {
+	var started, stopped int
+	cb := Callback{
+		OnSession:     func(NetSession) { started++ },
+		OnSessionStop: func(NetSession) { stopped++ },
+	}
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	n := WithNegotiation(Negotiation{Coders: []string{"json"}, Packers: []string{"lv"}})
+	s := newTestSession(subprotocolConn{Conn: c1, sub: SubprotocolPrefix + "unknown"}, cb, n)
+
+	runSession(t, s)
+	assert.Equal(t, 0, started)
+	assert.Equal(t, 0, stopped)
+
+	_, err := c2.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSession_RunHandshakeFailed(t *testing.T) {
+	var started, stopped int
+	cb := Callback{
+		OnSession:     func(NetSession) { started++ },
+		OnSessionStop: func(NetSession) { stopped++ },
+	}
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	s := newTestSession(c1, cb)
+	s.packer = failHandshakePacker{Packer: s.packer}
+
+	runSession(t, s)
+	assert.Equal(t, 1, started)
+	assert.Equal(t, 1, stopped)
+
+	_, err := c2.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSession_TimerWithoutModuleTimer(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	// module未设置timer时不会panic, 返回的Cancel可以调用
+	s := newTestSession(c1, Callback{})
+	assert.NotPanics(t, func() {
+		s.AfterFunc(time.Millisecond, func(time.Time) {})()
+		s.Every(time.Millisecond, func(time.Time) {})()
+	})
+}
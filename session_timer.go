@@ -0,0 +1,94 @@
+package gnet
+
+import (
+	"time"
+
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/MaxnSter/gnet/timer"
+	"github.com/golang/glog"
+)
+
+// AfterFunc 在d之后执行一次f, 见NetSession
+func (s *session) AfterFunc(d time.Duration, f timer.OnTimeOut) timer.Cancel {
+	return s.addTimer(d, 0, f)
+}
+
+// Every 每隔d执行一次f, 直到被取消或session关闭, 见NetSession
+func (s *session) Every(d time.Duration, f timer.OnTimeOut) timer.Cancel {
+	return s.addTimer(d, d, f)
+}
+
+func (s *session) addTimer(d, interval time.Duration, f timer.OnTimeOut) timer.Cancel {
+	if s.timer == nil {
+		// 不能因为module的配置错误让整个进程退出
+		glog.Errorf("session %d: module has no timer, see gnet.WithTimer, timer ignored", s.ID())
+		return func() {}
+	}
+
+	s.guard.Lock()
+	defer s.guard.Unlock()
+
+	// session已经关闭,定时器不会再被取消,直接忽略
+	select {
+	case <-s.closeCh:
+		return func() {}
+	default:
+	}
+
+	if s.timers == nil {
+		s.timers = map[uint64]timer.Cancel{}
+	}
+	s.timerSeq++
+	id := s.timerSeq
+
+	s.timers[id] = s.timer.AddTimer(s.clock.Now().Add(d), interval, func(now time.Time) {
+		// timer的callback可能在任意worker中执行,
+		// 重新以session投放到pool中,与OnMessage串行
+		s.pool.Put(func() {
+			if s.fired(id, interval <= 0) {
+				f(now)
+			}
+		}, pool.WithIdentify(s))
+	})
+
+	return func() {
+		s.cancelTimer(id)
+	}
+}
+
+// fired 判断定时器id在到期时是否仍然有效, once为true时同时将其移除
+func (s *session) fired(id uint64, once bool) bool {
+	s.guard.Lock()
+	defer s.guard.Unlock()
+
+	if _, ok := s.timers[id]; !ok {
+		return false
+	}
+	if once {
+		delete(s.timers, id)
+	}
+	return true
+}
+
+func (s *session) cancelTimer(id uint64) {
+	s.guard.Lock()
+	cancel, ok := s.timers[id]
+	delete(s.timers, id)
+	s.guard.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+// stopTimers 取消session所有的定时器
+func (s *session) stopTimers() {
+	s.guard.Lock()
+	timers := s.timers
+	s.timers = nil
+	s.guard.Unlock()
+
+	for _, cancel := range timers {
+		cancel()
+	}
+}
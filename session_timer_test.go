@@ -0,0 +1,204 @@
+package gnet
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet/clock"
+	"github.com/MaxnSter/gnet/codec"
+	"github.com/MaxnSter/gnet/packer/plugins/packer_length_value"
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/MaxnSter/gnet/pool/plugins/pool_race_self"
+	"github.com/MaxnSter/gnet/timer"
+	"github.com/MaxnSter/gnet/timer/plugins/timer_heap"
+	"github.com/MaxnSter/gnet/timer/plugins/timer_wheel"
+	"github.com/stretchr/testify/assert"
+)
+
+var timerStart = time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)
+
+// newTimerSession 返回使用fake时间的session, 调用返回的stop关闭module
+func newTimerSession(fake *clock.Fake, cb Callback) (*session, func()) {
+	m := NewModule(pool_race_self.New(), codec.MustGetCoder("json"), packer_length_value.New(),
+		WithTimer(timer_heap.New(timer_heap.WithClock(fake))), WithClock(fake))
+	runModule(m)
+
+	a, b := net.Pipe()
+	s := newSession(1, a, nil, m, NewOperator(m, cb), false).(*session)
+	return s, func() {
+		s.Stop()
+		b.Close()
+		stopModule(m)
+	}
+}
+
+func (s *session) timerCount() int {
+	s.guard.Lock()
+	defer s.guard.Unlock()
+	return len(s.timers)
+}
+
+func expectFired(t *testing.T, fired <-chan time.Time, want time.Time) {
+	select {
+	case now := <-fired:
+		assert.Equal(t, want, now)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timer at %s not fired", want)
+	}
+}
+
+func expectNotFired(t *testing.T, fired <-chan time.Time) {
+	select {
+	case now := <-fired:
+		t.Fatalf("unexpected timer at %s", now)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSessionTimer_AfterFunc(t *testing.T) {
+	fake := clock.NewFake(timerStart)
+	s, stop := newTimerSession(fake, Callback{})
+	defer stop()
+
+	fired := make(chan time.Time, 1)
+	s.AfterFunc(time.Second, func(now time.Time) { fired <- now })
+	assert.Equal(t, 1, s.timerCount())
+
+	fake.Advance(999 * time.Millisecond)
+	expectNotFired(t, fired)
+	fake.Advance(time.Millisecond)
+	expectFired(t, fired, timerStart.Add(time.Second))
+
+	// 一次性的定时器执行后被移除
+	assert.Equal(t, 0, s.timerCount())
+}
+
+func TestSessionTimer_ModuleClock(t *testing.T) {
+	for _, tm := range []timer.Timer{timer_heap.New(), timer_wheel.New()} {
+		fake := clock.NewFake(timerStart)
+		m := NewModule(pool_race_self.New(), codec.MustGetCoder("json"), packer_length_value.New(),
+			WithTimer(tm), WithClock(fake))
+		runModule(m)
+
+		a, b := net.Pipe()
+		s := newSession(1, a, nil, m, NewOperator(m, Callback{}), false).(*session)
+
+		// timer未使用fake创建, 运行时改为使用module的clock
+		fired := make(chan time.Time, 1)
+		s.AfterFunc(time.Second, func(now time.Time) { fired <- now })
+		expectNotFired(t, fired)
+		fake.Advance(time.Second)
+		select {
+		case <-fired:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: timer not fired", tm)
+		}
+
+		s.Stop()
+		b.Close()
+		stopModule(m)
+	}
+}
+
+func TestSessionTimer_Every(t *testing.T) {
+	fake := clock.NewFake(timerStart)
+	s, stop := newTimerSession(fake, Callback{})
+	defer stop()
+
+	fired := make(chan time.Time, 1)
+	cancel := s.Every(time.Second, func(now time.Time) { fired <- now })
+	for i := 1; i <= 3; i++ {
+		fake.Advance(time.Second)
+		expectFired(t, fired, timerStart.Add(time.Duration(i)*time.Second))
+	}
+	assert.Equal(t, 1, s.timerCount())
+
+	cancel()
+	assert.Equal(t, 0, s.timerCount())
+	fake.Advance(time.Second)
+	expectNotFired(t, fired)
+}
+
+func TestSessionTimer_Stop(t *testing.T) {
+	fake := clock.NewFake(timerStart)
+	s, stop := newTimerSession(fake, Callback{})
+	defer stop()
+
+	fired := make(chan time.Time, 2)
+	s.AfterFunc(time.Second, func(now time.Time) { fired <- now })
+	s.Every(time.Second, func(now time.Time) { fired <- now })
+
+	// session关闭时取消所有定时器, 之后添加的定时器同样不会执行
+	s.Stop()
+	assert.Equal(t, 0, s.timerCount())
+	s.AfterFunc(time.Second, func(now time.Time) { fired <- now })
+	fake.Advance(10 * time.Second)
+	expectNotFired(t, fired)
+}
+
+func TestSessionTimer_Serialized(t *testing.T) {
+	var (
+		guard  sync.Mutex
+		events []string
+	)
+	record := func(e string) {
+		guard.Lock()
+		events = append(events, e)
+		guard.Unlock()
+	}
+
+	inMsg, release := make(chan struct{}), make(chan struct{})
+	fake := clock.NewFake(timerStart)
+	s, stop := newTimerSession(fake, Callback{
+		OnMessage: func(Event) {
+			record("msg start")
+			close(inMsg)
+			<-release
+			record("msg end")
+		},
+	})
+	defer stop()
+
+	fired := make(chan time.Time, 1)
+	s.AfterFunc(time.Second, func(now time.Time) {
+		record("timer")
+		fired <- now
+	})
+
+	// OnMessage执行期间到期的定时器, 在OnMessage返回之后才执行
+	s.operator.PostEvent(&eventWrapper{eventSession: s, msg: "hello"})
+	<-inMsg
+	fake.Advance(time.Second)
+	expectNotFired(t, fired)
+	close(release)
+	expectFired(t, fired, timerStart.Add(time.Second))
+
+	guard.Lock()
+	assert.Equal(t, []string{"msg start", "msg end", "timer"}, events)
+	guard.Unlock()
+}
+
+// spyTimer 记录module对timer的调用
+type spyTimer struct {
+	timer.Timer
+	calls []string
+	pool  pool.Pool
+}
+
+func (t *spyTimer) Run()                { t.calls = append(t.calls, "run") }
+func (t *spyTimer) Stop()               { t.calls = append(t.calls, "stop") }
+func (t *spyTimer) SetPool(p pool.Pool) { t.pool = p; t.calls = append(t.calls, "set pool") }
+
+func TestModule_RunStopTimer(t *testing.T) {
+	spy := &spyTimer{}
+	m := NewModule(pool_race_self.New(), codec.MustGetCoder("json"), packer_length_value.New(), WithTimer(spy))
+
+	runModule(m)
+	assert.Equal(t, []string{"set pool", "run"}, spy.calls)
+	assert.Equal(t, m.Pool(), spy.pool)
+
+	stopModule(m)
+	assert.Equal(t, []string{"set pool", "run", "stop"}, spy.calls)
+}
@@ -0,0 +1,45 @@
+package timer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronSpec_Next(t *testing.T) {
+	// 2019-06-03 是周一
+	from := time.Date(2019, 6, 3, 10, 15, 30, 0, time.UTC)
+
+	cases := []struct {
+		spec   string
+		expect time.Time
+	}{
+		{"* * * * *", time.Date(2019, 6, 3, 10, 16, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2019, 6, 3, 10, 20, 0, 0, time.UTC)},
+		{"0 9-18/3 * * *", time.Date(2019, 6, 3, 12, 0, 0, 0, time.UTC)},
+		{"30 20 * * 5", time.Date(2019, 6, 7, 20, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2019, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2019, 6, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 日期与星期都被限制时满足其一即可
+		{"0 0 10 * 2", time.Date(2019, 6, 4, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		spec, err := ParseCron(c.spec)
+		assert.Nil(t, err, c.spec)
+		assert.Equal(t, c.expect, spec.Next(from), c.spec)
+	}
+
+	spec, err := ParseCron("0 0 30 2 *")
+	assert.Nil(t, err)
+	assert.True(t, spec.Next(from).IsZero())
+}
+
+func TestParseCron_Error(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := ParseCron(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
@@ -1,6 +1,10 @@
 package internal
 
-import "time"
+import (
+	"time"
+
+	"github.com/MaxnSter/gnet/clock"
+)
 
 /*
 This code is based on the following resources:
@@ -8,7 +12,7 @@ source code:	https://play.golang.org/p/Ys9qqanqmU
 discuss:	https://groups.google.com/forum/#!msg/golang-dev/c9UUfASVPoU/tlbK2BpFEwAJ
 */
 type SafeTimer struct {
-	*time.Timer
+	clock.Timer
 	bScr bool
 }
 
@@ -26,7 +30,7 @@ func (t *SafeTimer) SafeReset(d time.Duration) bool {
 	//其对应的callback的处理方式是(都是这个意思): go timer.callback()
 	//所以此时,此时如果timer.C没有被处理(scr为true), 我们就需要手动drain timer.C
 	if !ret && !t.bScr {
-		<-t.C
+		<-t.C()
 	}
 
 	t.Timer.Reset(d)
@@ -34,8 +38,8 @@ func (t *SafeTimer) SafeReset(d time.Duration) bool {
 	return ret
 }
 
-func NewSafeTimer(d time.Duration) *SafeTimer {
+func NewSafeTimer(c clock.Clock, d time.Duration) *SafeTimer {
 	return &SafeTimer{
-		Timer: time.NewTimer(d),
+		Timer: c.NewTimer(d),
 	}
 }
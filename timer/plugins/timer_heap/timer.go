@@ -2,35 +2,38 @@ package timer_heap
 
 import (
 	"container/heap"
-	"github.com/MaxnSter/gnet/timer"
-	"github.com/MaxnSter/gnet/timer/plugins/internal"
 	"math"
 	"sync"
 	"time"
 
+	"github.com/MaxnSter/gnet/clock"
 	"github.com/MaxnSter/gnet/pool"
-	"github.com/MaxnSter/gnet/util"
+	"github.com/MaxnSter/gnet/timer"
+	"github.com/MaxnSter/gnet/timer/plugins/internal"
 )
 
 /*
 一个基于最小堆的定时器
+
+堆由guard保护, 添加,取消与Reset都是O(log n),
+堆顶变化时通过wakeCh通知loop goroutine重新计算等待时间
 */
 
-//堆节点
+// 堆节点
 type timerEntry struct {
 	expire   time.Time     //到期时间
 	interval time.Duration //重复间隔
-	timerID  uint64
-	index    int //heap内部维护的index
+	gen      uint64        //每次回收时递增, 用于识别已经失效的handle
+	index    int           //heap内部维护的index
 
 	cb timer.OnTimeOut // callback
 }
 
 type timerHeap []*timerEntry
 
-//实现标准库heap接口
+// 实现标准库heap接口
 func (heap timerHeap) Len() int           { return len(heap) }
-func (heap timerHeap) Less(i, j int) bool { return heap[i].expire.Sub(heap[j].expire) < 0 }
+func (heap timerHeap) Less(i, j int) bool { return heap[i].expire.Before(heap[j].expire) }
 func (heap timerHeap) Swap(i, j int) {
 	heap[i], heap[j] = heap[j], heap[i]
 	heap[i].index, heap[j].index = i, j
@@ -47,22 +50,12 @@ func (heap *timerHeap) Pop() interface{} {
 	old := *heap
 	n := len(old)
 	tn := old[n-1]
+	old[n-1] = nil
 	tn.index = -1
 	*heap = old[0 : n-1]
 	return tn
 }
 
-//根据timerId来获取该timerId对应的timer在堆内的index
-func (heap timerHeap) getTimerIdx(timerID uint64) int {
-	for i := range heap {
-		if heap[i].timerID == timerID {
-			return heap[i].index
-		}
-	}
-
-	return -1
-}
-
 const (
 	//dummy duration,堆中不存在任何user timer时
 	//此参数作为sys timer的参数,这样可以一直阻塞
@@ -72,67 +65,93 @@ const (
 )
 
 var (
-	_ timer.Timer = (*timerManager)(nil)
-
-	ePool = &entryPool{
-		p: sync.Pool{
-			New: func() interface{} {
-				return new(timerEntry)
-			}},
-	}
+	_ timer.Timer       = (*timerManager)(nil)
+	_ timer.Scheduler   = (*timerManager)(nil)
+	_ timer.ClockSetter = (*timerManager)(nil)
+	_ timer.Handle      = (*handle)(nil)
 )
 
+// entryPool 回收堆节点, 每个timerManager持有自己的entryPool,
+// 节点只在同一个timerManager中复用, gen始终由该timerManager的guard保护.
+// get与put的调用方持有guard
 type entryPool struct {
 	p sync.Pool
 }
 
 func (ep *entryPool) get() *timerEntry {
-	return ep.p.Get().(*timerEntry)
+	if t, ok := ep.p.Get().(*timerEntry); ok {
+		return t
+	}
+	return new(timerEntry)
 }
 
 func (ep *entryPool) put(t *timerEntry) {
+	t.gen++
+	t.cb = nil
 	ep.p.Put(t)
 }
 
+// Option 是timerManager的配置
+type Option struct {
+	// Clock 定时器使用的时间, 默认为clock.Real
+	Clock clock.Clock
+}
+
+func WithClock(c clock.Clock) func(*Option) {
+	return func(o *Option) {
+		o.Clock = c
+	}
+}
+
 type timerManager struct {
-	pool        pool.Pool //负责处理callback的worker entryPool
-	timers      timerHeap //管理所有user timer的最小堆
-	pauseCh     chan struct{}
-	resumeCh    chan struct{}
-	closeCh     chan struct{}
-	closeDoneCh chan struct{}
-
-	sync.Once
+	pool  pool.Pool //负责处理callback的worker entryPool
+	clock clock.Clock
+
+	guard  sync.Mutex
+	timers timerHeap //管理所有user timer的最小堆
+	free   entryPool //回收的堆节点
+
+	wakeCh   chan struct{}
+	closeCh  chan struct{}
+	doneCh   chan struct{}
+	once     sync.Once
+	stopOnce sync.Once
+}
+
+// handle 记录创建时堆节点的gen, 节点被回收后handle的所有操作都不再生效
+type handle struct {
+	tm  *timerManager
+	e   *timerEntry
+	gen uint64
 }
 
 func init() {
-	timer.RegisterTimer(Name, New)
+	timer.RegisterTimer(Name, func() timer.Timer {
+		return New()
+	})
 }
 
-func New() timer.Timer {
-	return newTimerManager()
+func New(opts ...func(*Option)) timer.Timer {
+	return newTimerManager(opts...)
 }
 
-func newTimerManager() timer.Timer {
+func newTimerManager(opts ...func(*Option)) *timerManager {
+	opt := Option{}
+	for _, f := range opts {
+		f(&opt)
+	}
+
 	tm := &timerManager{
-		timers:      make([]*timerEntry, 0),
-		pauseCh:     make(chan struct{}),
-		resumeCh:    make(chan struct{}),
-		closeCh:     make(chan struct{}),
-		closeDoneCh: make(chan struct{}),
+		clock:   clock.OrReal(opt.Clock),
+		timers:  make([]*timerEntry, 0),
+		wakeCh:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
 	}
 
 	return tm
 }
 
-func (tm *timerManager) put(t *timerEntry) {
-	ePool.put(t)
-}
-
-func (tm *timerManager) get() *timerEntry {
-	return ePool.get()
-}
-
 func (tm *timerManager) String() string {
 	return Name
 }
@@ -141,147 +160,168 @@ func (tm *timerManager) SetPool(p pool.Pool) {
 	tm.pool = p
 }
 
-func (tm *timerManager) Run() {
-	tm.Once.Do(func() {
+// SetClock更换定时器使用的时间, 需要在Run之前调用
+func (tm *timerManager) SetClock(c clock.Clock) {
+	tm.clock = clock.OrReal(c)
+}
 
-		heap.Init(&tm.timers)
+func (tm *timerManager) Run() {
+	tm.once.Do(func() {
 		go tm.run()
 	})
 }
 
-func (tm *timerManager) stopAsync() (done <-chan struct{}) {
-	tm.closeCh <- struct{}{}
-
-	return tm.closeDoneCh
-}
-
 // Stop关闭定时器,调用方阻塞直到定时器完全关闭
 func (tm *timerManager) Stop() {
-	<-tm.stopAsync()
+	tm.stopOnce.Do(func() {
+		close(tm.closeCh)
+	})
+
+	// 未Run时没有需要等待的goroutine
+	tm.once.Do(func() {
+		close(tm.doneCh)
+	})
+	<-tm.doneCh
 }
 
-// AddTimer添加一个定时任务,并返回该任务对应的id
+// AddTimer添加一个定时任务,并返回取消该任务的Cancel
 func (tm *timerManager) AddTimer(expire time.Time, interval time.Duration, cb timer.OnTimeOut) timer.Cancel {
-	t := tm.get()
+	return tm.Schedule(expire, interval, cb).Cancel
+}
 
+// Schedule与AddTimer相同,但返回可以Reset的handle
+func (tm *timerManager) Schedule(expire time.Time, interval time.Duration, cb timer.OnTimeOut) timer.Handle {
+	tm.guard.Lock()
+	t := tm.free.get()
 	t.expire = expire
 	t.interval = interval
 	t.cb = cb
-	t.timerID = util.GetUUID()
-	t.index = -1
+	heap.Push(&tm.timers, t)
+	h := &handle{tm: tm, e: t, gen: t.gen}
+	top := t.index == 0
+	tm.guard.Unlock()
 
-	tm.pause()
-	tm.timers.Push(t)
-	tm.resume()
+	if top {
+		tm.wake()
+	}
+	return h
+}
 
-	return func() {
-		tm.CancelTimer(t.timerID)
+// remove将t从堆中移除并回收, 调用方持有guard
+func (tm *timerManager) remove(t *timerEntry) {
+	if t.index >= 0 {
+		heap.Remove(&tm.timers, t.index)
 	}
+	tm.free.put(t)
 }
 
-// CancelTimer取消一个定时
-// node:如果该timer为一次性(interval = 0)且正好expire, 则取消无效
-func (tm *timerManager) CancelTimer(id uint64) {
-	idx := tm.timers.getTimerIdx(id)
-	if idx == -1 {
-		return
+func (h *handle) Cancel() {
+	h.tm.guard.Lock()
+	defer h.tm.guard.Unlock()
+
+	if h.e.gen == h.gen {
+		h.tm.remove(h.e)
 	}
+}
 
-	tm.pause()
-	t := heap.Remove(&tm.timers, idx)
-	tm.resume()
+func (h *handle) Reset(expire time.Time) bool {
+	tm := h.tm
+	tm.guard.Lock()
+	if h.e.gen != h.gen {
+		tm.guard.Unlock()
+		return false
+	}
 
-	tm.put(t.(*timerEntry))
+	h.e.expire = expire
+	heap.Fix(&tm.timers, h.e.index)
+	tm.guard.Unlock()
+
+	// 堆顶可能变化, 不论移至堆顶还是从堆顶移走, 都需要重新计算等待时间
+	tm.wake()
+	return true
 }
 
-func (tm *timerManager) pause() {
-	tm.pauseCh <- struct{}{}
+// wake通知loop goroutine重新计算等待时间
+func (tm *timerManager) wake() {
+	select {
+	case tm.wakeCh <- struct{}{}:
+	default:
+	}
 }
 
-func (tm *timerManager) resume() {
-	tm.resumeCh <- struct{}{}
+// next返回距离最近一个user timer到期的时间
+func (tm *timerManager) next() time.Duration {
+	tm.guard.Lock()
+	defer tm.guard.Unlock()
+
+	if len(tm.timers) == 0 {
+		return UNTOUCHED
+	}
+	return tm.clock.Until(tm.timers[0].expire)
 }
 
 func (tm *timerManager) run() {
 	var (
-		timeout      time.Duration
-		loopTimer    = internal.NewSafeTimer(UNTOUCHED)
-		expiredTNode []*timerEntry
+		loopTimer = internal.NewSafeTimer(tm.clock, UNTOUCHED)
+		expired   []timer.OnTimeOut
 	)
 
 	defer func() {
 		loopTimer.Stop()
-		close(tm.closeDoneCh)
+		close(tm.doneCh)
 	}()
 
 	for {
-		if len(tm.timers) > 0 {
-			//选取一个最近时间的user timer
-			timeout = tm.timers[0].expire.Sub(time.Now())
-		} else {
-			timeout = UNTOUCHED
-		}
-		loopTimer.SafeReset(timeout)
+		loopTimer.SafeReset(tm.next())
 
 		select {
-		case <-tm.pauseCh:
-			//wait for resume signal
-			<-tm.resumeCh
+		case <-tm.wakeCh:
 		case <-tm.closeCh:
 			return
-		case <-loopTimer.Timer.C:
+		case <-loopTimer.C():
 			loopTimer.Scr()
-			tm.expired(&expiredTNode)
+			now := tm.clock.Now()
+			expired = tm.expired(now, expired[:0])
+			for i, cb := range expired {
+				tm.handleExpired(cb, now)
+				expired[i] = nil
+			}
 		}
 	}
 }
 
-//处理expired user timer的callback, 该func保证非阻塞执行
-func (tm *timerManager) handleExpired(entry *timerEntry, t time.Time) {
-	f := func() {
-		entry.cb(t)
-	}
-
-	if !tm.pool.TryPut(f) {
-		//must be async
-		go tm.pool.Put(f)
-	}
+// 将expired user timer的callback投放到pool中执行,
+// pool的Put不限制缓存的任务数量, callback按到期的顺序投放
+func (tm *timerManager) handleExpired(cb timer.OnTimeOut, t time.Time) {
+	tm.pool.Put(func() {
+		cb(t)
+	})
 }
 
-//有一个或多个user timer 到期
-func (tm *timerManager) expired(expiredTNode *[]*timerEntry) {
+// 取出所有到期的user timer的callback, 一次性的timer被回收,
+// interval不为0的timer以固定频率调整expire time后留在堆中
+func (tm *timerManager) expired(now time.Time, cbs []timer.OnTimeOut) []timer.OnTimeOut {
+	tm.guard.Lock()
+	defer tm.guard.Unlock()
+
 	for len(tm.timers) > 0 {
-		t := heap.Pop(&tm.timers).(*timerEntry)
-		if time.Since(t.expire) > 0 {
-			*expiredTNode = append(*expiredTNode, t)
-			tm.handleExpired(t, time.Now())
-		} else {
-			heap.Push(&tm.timers, t)
+		t := tm.timers[0]
+		if t.expire.After(now) {
 			break
 		}
-	}
 
-	tm.update(*expiredTNode)
-	for i := range *expiredTNode {
-		(*expiredTNode)[i] = nil
-	}
-	*expiredTNode = (*expiredTNode)[:0]
-}
-
-//对所有interval不为0的user timer,调整expire time,重新入堆
-func (tm *timerManager) update(tNodes []*timerEntry) {
-
-	if len(tNodes) == 0 {
-		return
-	}
-
-	for i, v := range tNodes {
-		if v.interval <= 0 {
-			tm.put(tNodes[i])
+		cbs = append(cbs, t.cb)
+		if t.interval <= 0 {
+			tm.remove(t)
 			continue
 		}
 
-		v.expire = time.Now().Add(v.interval)
-		heap.Push(&tm.timers, tNodes[i])
+		// 从上一次的到期时间开始计算, 避免漂移, 落后超过一个interval时跳过错过的执行
+		t.expire = t.expire.Add(t.interval)
+		if !t.expire.After(now) {
+			t.expire = t.expire.Add((now.Sub(t.expire)/t.interval + 1) * t.interval)
+		}
+		heap.Fix(&tm.timers, 0)
 	}
+	return cbs
 }
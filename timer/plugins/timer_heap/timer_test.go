@@ -1,87 +1,253 @@
 package timer_heap
 
 import (
-	"math"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/MaxnSter/gnet/iface"
-	"github.com/MaxnSter/gnet/pool"
-	_ "github.com/MaxnSter/gnet/pool/pool_norace"
+	"github.com/MaxnSter/gnet/clock"
+	"github.com/MaxnSter/gnet/pool/plugins/pool_race_other"
 	"github.com/stretchr/testify/assert"
 )
 
-var wPool pool.Pool
+func newTestManager() (*timerManager, func()) {
+	p := pool_race_other.New()
+	p.Run()
 
-func TestNewTimerManager(t *testing.T) {
-	wPool = pool.MustGetWorkerPool("poolNoRace")
-	wPool.Start()
-
-	tw := NewWithPool(wPool)
-	assert.NotNil(t, tw, "tw should not be nil")
+	tm := newTimerManager()
+	tm.SetPool(p)
+	tm.Run()
+	return tm, func() {
+		tm.Stop()
+		p.Stop()
+	}
 }
 
 func TestTimerManager_AddTimer(t *testing.T) {
-	wPool = pool.MustGetWorkerPool("poolNoRace")
-	wPool.Start()
+	tm, stop := newTestManager()
+	defer stop()
+
+	// 按到期时间的逆序添加, 按到期时间的顺序执行
+	fired := make(chan int, 5)
+	start := time.Now()
+	for i := 4; i >= 0; i-- {
+		i, expire := i, start.Add(time.Duration(i+1)*10*time.Millisecond)
+		tm.AddTimer(expire, 0, func(now time.Time) {
+			assert.False(t, now.Before(expire), "fired before expire")
+			fired <- i
+		})
+	}
+
+	for i := 0; i < 5; i++ {
+		select {
+		case n := <-fired:
+			assert.Equal(t, i, n)
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
 
-	tw := NewWithPool(wPool)
-	assert.NotNil(t, tw, "tw should not be nil")
-	tw.Start()
+func TestTimerManager_Interval(t *testing.T) {
+	tm, stop := newTestManager()
+	defer stop()
 
-	wg := sync.WaitGroup{}
-	timerIds := make([]int64, 0)
+	ticks := make(chan struct{}, 16)
+	h := tm.Schedule(time.Now(), 5*time.Millisecond, func(time.Time) {
+		ticks <- struct{}{}
+	})
 
-	for i := 0; i < 10000; i++ {
-		id := tw.AddTimer(time.Now(), time.Second, nil, func(i time.Time, ctx iface.Context) {
-			wg.Add(1)
-			for i := 0; i < math.MaxInt16; i++ {
-			}
-			wg.Done()
-		})
-		timerIds = append(timerIds, id)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ticks:
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
 	}
 
-	var stopId int64
-	wg.Add(1)
-	stopId = tw.AddTimer(time.Now().Add(5*time.Second), 0, nil, func(i time.Time, ctx iface.Context) {
-		tw.CancelTimer(stopId)
-		for _, id := range timerIds {
-			tw.CancelTimer(id)
-		}
-		wg.Done()
+	h.Cancel()
+	assert.False(t, h.Reset(time.Now()))
+
+	tm.guard.Lock()
+	assert.Len(t, tm.timers, 0)
+	tm.guard.Unlock()
+}
+
+func TestTimerManager_Reset(t *testing.T) {
+	tm, stop := newTestManager()
+	defer stop()
+
+	fired := make(chan time.Time, 1)
+	h := tm.Schedule(time.Now().Add(time.Hour), 0, func(now time.Time) {
+		fired <- now
 	})
 
-	wg.Wait()
+	expire := time.Now().Add(10 * time.Millisecond)
+	assert.True(t, h.Reset(expire))
+
+	select {
+	case now := <-fired:
+		assert.False(t, now.Before(expire), "fired before expire")
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+
+	// 一次性的定时任务到期后handle失效
+	assert.False(t, h.Reset(time.Now()))
 }
 
-func TestTimerManager_Stop(t *testing.T) {
-	wPool = pool.MustGetWorkerPool("poolNoRace")
-	wPool.Start()
+func TestTimerManager_StaleHandle(t *testing.T) {
+	tm, stop := newTestManager()
+	defer stop()
 
-	tw := NewWithPool(wPool)
-	assert.NotNil(t, tw, "tw should not be nil")
-	tw.Start()
+	fired := make(chan int, 2)
+	h1 := tm.Schedule(time.Now(), 0, func(time.Time) {
+		fired <- 1
+	})
+	assert.Equal(t, 1, <-fired)
+
+	// h1的节点已经回收, 可能被h2复用, 对h1的操作不能影响h2
+	h2 := tm.Schedule(time.Now().Add(10*time.Millisecond), 0, func(time.Time) {
+		fired <- 2
+	})
+	h1.Cancel()
+	assert.False(t, h1.Reset(time.Now().Add(time.Hour)))
+
+	select {
+	case n := <-fired:
+		assert.Equal(t, 2, n)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	h2.Cancel()
+}
+
+func TestTimerManager_ConcurrentCancel(t *testing.T) {
+	tm, stop := newTestManager()
+	defer stop()
 
 	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cancel := tm.AddTimer(time.Now().Add(time.Hour), 0, func(time.Time) {})
+				cancel()
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	tm.guard.Lock()
+	assert.Len(t, tm.timers, 0)
+	tm.guard.Unlock()
+}
+
+func TestTimerManager_StaleHandleOtherManager(t *testing.T) {
+	tm1, stop1 := newTestManager()
+	defer stop1()
+	tm2, stop2 := newTestManager()
+	defer stop2()
+
+	fired := make(chan struct{}, 1)
+	h := tm1.Schedule(time.Now(), 0, func(time.Time) {
+		fired <- struct{}{}
+	})
+	<-fired
+
+	// h的节点只会在tm1中复用, 对h的操作与tm2互不影响(go test -race)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			tm2.AddTimer(time.Now().Add(time.Hour), 0, func(time.Time) {})()
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		h.Cancel()
+		assert.False(t, h.Reset(time.Now().Add(time.Hour)))
+	}
+	<-done
+}
 
-	for i := 0; i < 10000; i++ {
-		tw.AddTimer(time.Now(), time.Second, nil, func(i time.Time, ctx iface.Context) {
-			wg.Add(1)
-			for i := 0; i < math.MaxInt16; i++ {
+func TestTimerManager_FullPool(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	p := pool_race_other.New(pool_race_other.WithQueueSize(1))
+	p.Run()
+	defer p.Stop()
+
+	tm := newTimerManager(WithClock(fake))
+	tm.SetPool(p)
+	tm.Run()
+	defer tm.Stop()
+
+	block := make(chan struct{})
+	p.Put(func() { <-block })
+
+	// pool已满时callback仍然按到期的顺序投放
+	var order []int
+	done := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		i := i
+		tm.AddTimer(fake.Now().Add(time.Duration(i+1)*time.Second), 0, func(time.Time) {
+			if order = append(order, i); len(order) == 5 {
+				close(done)
 			}
-			wg.Done()
 		})
 	}
+	for i := 0; i < 5; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(time.Second)
+	}
+	close(block)
+
+	select {
+	case <-done:
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timers not fired")
+	}
+}
+
+func TestTimerManager_Stop(t *testing.T) {
+	tm := newTimerManager()
+	tm.Stop()
+	tm.Stop()
+
+	tm, stop := newTestManager()
+	stop()
 
-	var stopId int64
-	wg.Add(1)
-	stopId = tw.AddTimer(time.Now().Add(5*time.Second), 0, nil, func(i time.Time, ctx iface.Context) {
-		tw.CancelTimer(stopId)
-		tw.Stop()
-		wg.Done()
+	// Stop之后添加与取消不会阻塞
+	tm.AddTimer(time.Now(), 0, func(time.Time) {})()
+}
+
+func TestTimerManager_FakeClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	p := pool_race_other.New()
+	p.Run()
+	defer p.Stop()
+
+	tm := newTimerManager(WithClock(fake))
+	tm.SetPool(p)
+	tm.Run()
+	defer tm.Stop()
+
+	fired := make(chan int, 4)
+	start := fake.Now()
+	tm.AddTimer(start.Add(time.Second), time.Second, func(time.Time) {
+		fired <- 1
+	})
+	tm.AddTimer(start.Add(2500*time.Millisecond), 0, func(time.Time) {
+		fired <- 2
 	})
 
-	wg.Wait()
+	for _, expect := range [][]int{{1}, {1}, {2, 1}} {
+		fake.Advance(time.Second)
+		for _, n := range expect {
+			assert.Equal(t, n, <-fired)
+		}
+	}
+	assert.Len(t, fired, 0)
 }
@@ -0,0 +1,395 @@
+package timer_wheel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MaxnSter/gnet/clock"
+	"github.com/MaxnSter/gnet/pool"
+	"github.com/MaxnSter/gnet/timer"
+)
+
+/*
+一个分层时间轮定时器, 添加与取消都是O(1)
+
+第i层时间轮的每个slot跨度为tick*slots^i, 到期时间超出当前层范围的定时器放入上层,
+上层slot到达时将其中的定时器重新放入下层(cascade), 超出最上层范围的定时器
+放入最上层最远的slot, 到达时重新计算位置
+*/
+
+const (
+	Name = "timer_wheel"
+
+	// 默认精度10ms, 每层256个slot, 4层可以覆盖约497天
+	DefaultTick   = 10 * time.Millisecond
+	DefaultSlots  = 256
+	DefaultLevels = 4
+)
+
+var (
+	_ timer.Timer     = (*timerWheel)(nil)
+	_ timer.Scheduler = (*timerWheel)(nil)
+	_ timer.Handle    = (*handle)(nil)
+)
+
+// Option 是timerWheel的配置
+type Option struct {
+	// Tick 时间轮的精度, 定时器最多延迟一个Tick到期
+	Tick time.Duration
+
+	// Slots 每层时间轮的slot数量
+	Slots int
+
+	// Levels 时间轮的层数
+	Levels int
+
+	// Clock 定时器使用的时间, 默认为clock.Real
+	Clock clock.Clock
+}
+
+func WithTick(tick time.Duration) func(*Option) {
+	return func(o *Option) {
+		o.Tick = tick
+	}
+}
+
+func WithSlots(slots int) func(*Option) {
+	return func(o *Option) {
+		o.Slots = slots
+	}
+}
+
+func WithLevels(levels int) func(*Option) {
+	return func(o *Option) {
+		o.Levels = levels
+	}
+}
+
+func WithClock(c clock.Clock) func(*Option) {
+	return func(o *Option) {
+		o.Clock = c
+	}
+}
+
+// timerEntry 是slot双向链表中的节点
+type timerEntry struct {
+	tick     uint64 // 到期的tick
+	interval uint64 // 重复间隔, 以tick计, 0表示一次性
+	cb       timer.OnTimeOut
+	done     bool // 一次性任务已经到期或任务已经取消
+
+	slot       *timerEntry // 所在slot的哨兵节点, nil表示不在时间轮中
+	prev, next *timerEntry
+}
+
+// slot 是一个带哨兵节点的双向链表
+type slot struct {
+	timerEntry
+}
+
+func newSlot() *slot {
+	s := &slot{}
+	s.prev, s.next = &s.timerEntry, &s.timerEntry
+	return s
+}
+
+func (s *slot) push(e *timerEntry) {
+	head := &s.timerEntry
+	e.slot = head
+	e.prev, e.next = head.prev, head
+	head.prev.next = e
+	head.prev = e
+}
+
+// take 取出slot中所有的节点
+func (s *slot) take() (entries []*timerEntry) {
+	head := &s.timerEntry
+	for e := head.next; e != head; {
+		next := e.next
+		e.slot, e.prev, e.next = nil, nil, nil
+		entries = append(entries, e)
+		e = next
+	}
+	head.prev, head.next = head, head
+	return
+}
+
+func (e *timerEntry) remove() {
+	if e.slot == nil {
+		return
+	}
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.slot, e.prev, e.next = nil, nil, nil
+}
+
+// level 是一层时间轮
+type level struct {
+	span  uint64 // 每个slot跨度的tick数
+	slots []*slot
+}
+
+type timerWheel struct {
+	opt    Option
+	pool   pool.Pool
+	start  time.Time
+	levels []*level
+
+	guard   sync.Mutex
+	current uint64 // 已经处理完毕的tick
+
+	once     sync.Once
+	stopOnce sync.Once
+	closeCh  chan struct{}
+	doneCh   chan struct{}
+}
+
+func init() {
+	timer.RegisterTimer(Name, func() timer.Timer {
+		return New()
+	})
+}
+
+func New(opts ...func(*Option)) timer.Timer {
+	opt := Option{
+		Tick:   DefaultTick,
+		Slots:  DefaultSlots,
+		Levels: DefaultLevels,
+	}
+	for _, f := range opts {
+		f(&opt)
+	}
+	if opt.Tick <= 0 {
+		opt.Tick = DefaultTick
+	}
+	if opt.Slots < 2 {
+		opt.Slots = DefaultSlots
+	}
+	if opt.Levels < 1 {
+		opt.Levels = DefaultLevels
+	}
+	opt.Clock = clock.OrReal(opt.Clock)
+
+	tw := &timerWheel{
+		opt:     opt,
+		start:   opt.Clock.Now(),
+		levels:  make([]*level, opt.Levels),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+
+	span := uint64(1)
+	for i := range tw.levels {
+		l := &level{span: span, slots: make([]*slot, opt.Slots)}
+		for j := range l.slots {
+			l.slots[j] = newSlot()
+		}
+		tw.levels[i] = l
+		span *= uint64(opt.Slots)
+	}
+	return tw
+}
+
+func (tw *timerWheel) String() string {
+	return Name
+}
+
+func (tw *timerWheel) SetPool(p pool.Pool) {
+	tw.pool = p
+}
+
+// SetClock更换定时器使用的时间, 需要在Run与AddTimer之前调用
+func (tw *timerWheel) SetClock(c clock.Clock) {
+	tw.opt.Clock = clock.OrReal(c)
+	tw.start = tw.opt.Clock.Now()
+}
+
+func (tw *timerWheel) Run() {
+	tw.once.Do(func() {
+		go tw.run()
+	})
+}
+
+// Stop关闭定时器,调用方阻塞直到定时器完全关闭, 未到期的定时器不再执行
+func (tw *timerWheel) Stop() {
+	tw.stopOnce.Do(func() {
+		close(tw.closeCh)
+	})
+
+	// 未Run时没有需要等待的goroutine
+	tw.once.Do(func() {
+		close(tw.doneCh)
+	})
+	<-tw.doneCh
+}
+
+// AddTimer添加一个定时任务, 返回的Cancel可以在任意goroutine中多次调用
+func (tw *timerWheel) AddTimer(expire time.Time, interval time.Duration, cb timer.OnTimeOut) timer.Cancel {
+	return tw.Schedule(expire, interval, cb).Cancel
+}
+
+// Schedule与AddTimer相同,但返回可以Reset的handle
+func (tw *timerWheel) Schedule(expire time.Time, interval time.Duration, cb timer.OnTimeOut) timer.Handle {
+	e := &timerEntry{
+		interval: tw.ticks(interval),
+		cb:       cb,
+	}
+
+	tw.guard.Lock()
+	tw.addAt(e, expire)
+	tw.guard.Unlock()
+
+	return &handle{tw: tw, e: e}
+}
+
+// handle 持有的节点不会被复用, 节点结束后handle的所有操作都不再生效
+type handle struct {
+	tw *timerWheel
+	e  *timerEntry
+}
+
+func (h *handle) Cancel() {
+	h.tw.guard.Lock()
+	h.e.remove()
+	h.e.done = true
+	h.tw.guard.Unlock()
+}
+
+func (h *handle) Reset(expire time.Time) bool {
+	h.tw.guard.Lock()
+	defer h.tw.guard.Unlock()
+
+	if h.e.done {
+		return false
+	}
+	h.e.remove()
+	h.tw.addAt(h.e, expire)
+	return true
+}
+
+// addAt 将e放入expire对应的slot, 调用方持有guard
+func (tw *timerWheel) addAt(e *timerEntry, expire time.Time) {
+	e.tick = tw.tickOf(expire)
+	if e.tick <= tw.current {
+		// 已经到期, 在下一个tick执行
+		e.tick = tw.current + 1
+	}
+	tw.add(e)
+}
+
+// tickOf 返回t所在的tick, 向上取整, 保证定时器不会提前到期
+func (tw *timerWheel) tickOf(t time.Time) uint64 {
+	d := t.Sub(tw.start)
+	if d <= 0 {
+		return 0
+	}
+	return uint64((d + tw.opt.Tick - 1) / tw.opt.Tick)
+}
+
+// elapsed 返回到t为止已经完整经过的tick数, 与tickOf配合保证定时器不会提前到期
+func (tw *timerWheel) elapsed(t time.Time) uint64 {
+	d := t.Sub(tw.start)
+	if d <= 0 {
+		return 0
+	}
+	return uint64(d / tw.opt.Tick)
+}
+
+// ticks 返回d对应的tick数, 不为0的d至少为1个tick
+func (tw *timerWheel) ticks(d time.Duration) uint64 {
+	if d <= 0 {
+		return 0
+	}
+	return uint64((d + tw.opt.Tick - 1) / tw.opt.Tick)
+}
+
+// add 将e放入对应的slot, 调用方持有guard且e.tick > current
+func (tw *timerWheel) add(e *timerEntry) {
+	delta := e.tick - tw.current
+	slots := uint64(tw.opt.Slots)
+
+	for _, l := range tw.levels {
+		if delta < l.span*slots {
+			l.slots[(e.tick/l.span)%slots].push(e)
+			return
+		}
+	}
+
+	// 超出最上层的范围, 放入最上层最远的slot, 到达时重新计算位置
+	top := tw.levels[len(tw.levels)-1]
+	top.slots[(tw.current/top.span+slots-1)%slots].push(e)
+}
+
+func (tw *timerWheel) run() {
+	ticker := tw.opt.Clock.NewTicker(tw.opt.Tick)
+	defer func() {
+		ticker.Stop()
+		close(tw.doneCh)
+	}()
+
+	var expired []*timerEntry
+	for {
+		select {
+		case <-tw.closeCh:
+			return
+		case <-ticker.C():
+			// 来不及接收的tick会被丢弃, 以当前时间追赶
+			now := tw.opt.Clock.Now()
+			expired = tw.advance(tw.elapsed(now), expired[:0])
+			for i, e := range expired {
+				tw.handleExpired(e.cb, now)
+				expired[i] = nil
+			}
+		}
+	}
+}
+
+// advance 将时间轮推进到target, 返回期间到期的定时器
+func (tw *timerWheel) advance(target uint64, expired []*timerEntry) []*timerEntry {
+	tw.guard.Lock()
+	defer tw.guard.Unlock()
+
+	slots := uint64(tw.opt.Slots)
+	for tw.current < target {
+		tw.current++
+
+		// 从上往下cascade, 到达slot边界的上层slot中的定时器放回下层
+		for i := len(tw.levels) - 1; i > 0; i-- {
+			l := tw.levels[i]
+			if tw.current%l.span == 0 {
+				for _, e := range l.slots[(tw.current/l.span)%slots].take() {
+					tw.reAdd(e, &expired)
+				}
+			}
+		}
+
+		for _, e := range tw.levels[0].slots[tw.current%slots].take() {
+			tw.reAdd(e, &expired)
+		}
+	}
+	return expired
+}
+
+// reAdd 到期的定时器加入expired, 重复定时器重新放入时间轮, 否则重新计算位置
+func (tw *timerWheel) reAdd(e *timerEntry, expired *[]*timerEntry) {
+	if e.tick > tw.current {
+		tw.add(e)
+		return
+	}
+
+	*expired = append(*expired, e)
+	if e.interval > 0 {
+		e.tick = tw.current + e.interval
+		tw.add(e)
+	} else {
+		e.done = true
+	}
+}
+
+// handleExpired 将到期定时器的callback投放到pool中执行,
+// pool的Put不限制缓存的任务数量, callback按到期的顺序投放
+func (tw *timerWheel) handleExpired(cb timer.OnTimeOut, t time.Time) {
+	tw.pool.Put(func() {
+		cb(t)
+	})
+}
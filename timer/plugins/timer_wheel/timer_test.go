@@ -0,0 +1,196 @@
+package timer_wheel
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet/clock"
+	"github.com/MaxnSter/gnet/pool/plugins/pool_race_other"
+	"github.com/MaxnSter/gnet/timer"
+	"github.com/MaxnSter/gnet/timer/plugins/timer_heap"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTimer(tm timer.Timer) (timer.Timer, func()) {
+	p := pool_race_other.New()
+	p.Run()
+
+	tm.SetPool(p)
+	tm.Run()
+	return tm, func() {
+		tm.Stop()
+		p.Stop()
+	}
+}
+
+func TestTimerWheel_AddTimer(t *testing.T) {
+	// 2层, 每层4个slot, 只能覆盖16ms, 其余的定时器需要cascade或重新计算位置
+	tw, stop := newTestTimer(New(WithTick(time.Millisecond), WithSlots(4), WithLevels(2)))
+	defer stop()
+
+	fired := make(chan int, 3)
+	start := time.Now()
+	for i, d := range []time.Duration{3, 12, 60} {
+		i, expire := i, start.Add(d*time.Millisecond)
+		tw.AddTimer(expire, 0, func(now time.Time) {
+			assert.False(t, now.Before(expire), "fired before expire")
+			fired <- i
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case n := <-fired:
+			assert.Equal(t, i, n)
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestTimerWheel_Cancel(t *testing.T) {
+	tw, stop := newTestTimer(New(WithTick(time.Millisecond)))
+	defer stop()
+
+	var fired int32
+	cancel := tw.AddTimer(time.Now().Add(20*time.Millisecond), 0, func(time.Time) {
+		atomic.AddInt32(&fired, 1)
+	})
+	cancel()
+	cancel()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fired))
+}
+
+func TestTimerWheel_Interval(t *testing.T) {
+	tw, stop := newTestTimer(New(WithTick(time.Millisecond)))
+	defer stop()
+
+	ticks := make(chan struct{}, 16)
+	cancel := tw.AddTimer(time.Now(), 5*time.Millisecond, func(time.Time) {
+		ticks <- struct{}{}
+	})
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ticks:
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	cancel()
+
+	// 取消前可能已经有callback投放到pool中
+	time.Sleep(20 * time.Millisecond)
+	for len(ticks) > 0 {
+		<-ticks
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, ticks, 0)
+}
+
+func TestTimerWheel_StopWithoutRun(t *testing.T) {
+	tw := New()
+	tw.Stop()
+	tw.Stop()
+}
+
+// 已有n个定时器时, 添加并取消一个定时器的开销
+func benchmarkAddCancel(b *testing.B, tm timer.Timer, n int) {
+	tm, stop := newTestTimer(tm)
+	defer stop()
+
+	noop := func(time.Time) {}
+	expire := time.Now().Add(time.Hour)
+	for i := 0; i < n; i++ {
+		tm.AddTimer(expire, 0, noop)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tm.AddTimer(expire, 0, noop)()
+	}
+}
+
+func BenchmarkTimerWheel_AddCancel_1K(b *testing.B) {
+	benchmarkAddCancel(b, New(), 1000)
+}
+
+func BenchmarkTimerWheel_AddCancel_100K(b *testing.B) {
+	benchmarkAddCancel(b, New(), 100000)
+}
+
+func BenchmarkTimerHeap_AddCancel_1K(b *testing.B) {
+	benchmarkAddCancel(b, timer_heap.New(), 1000)
+}
+
+func BenchmarkTimerHeap_AddCancel_100K(b *testing.B) {
+	benchmarkAddCancel(b, timer_heap.New(), 100000)
+}
+
+func TestTimerWheel_NotEarly(t *testing.T) {
+	tw, stop := newTestTimer(New(WithTick(10 * time.Millisecond)))
+	defer stop()
+
+	const n = 20
+	done := make(chan struct{}, n)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		// 到期时间不对齐tick的边界
+		expire := start.Add(time.Duration(i*7+3) * time.Millisecond)
+		tw.AddTimer(expire, 0, func(now time.Time) {
+			assert.False(t, time.Now().Before(expire), "fired before expire")
+			assert.False(t, now.Before(expire), "callback time before expire")
+			done <- struct{}{}
+		})
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestTimerWheel_Reset(t *testing.T) {
+	tw, stop := newTestTimer(New(WithTick(time.Millisecond)))
+	defer stop()
+
+	fired := make(chan time.Time, 1)
+	h := tw.(*timerWheel).Schedule(time.Now().Add(time.Hour), 0, func(now time.Time) {
+		fired <- now
+	})
+
+	expire := time.Now().Add(10 * time.Millisecond)
+	assert.True(t, h.Reset(expire))
+
+	select {
+	case now := <-fired:
+		assert.False(t, now.Before(expire), "fired before expire")
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	assert.False(t, h.Reset(time.Now()))
+}
+
+func TestTimerWheel_FakeClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	tw, stop := newTestTimer(New(WithTick(time.Second), WithClock(fake)))
+	defer stop()
+
+	fired := make(chan time.Time, 1)
+	expire := fake.Now().Add(3 * time.Second)
+	tw.AddTimer(expire, 0, func(now time.Time) {
+		fired <- now
+	})
+
+	// 等待时间轮的ticker开始等待
+	fake.BlockUntil(1)
+	fake.Advance(2 * time.Second)
+	fake.Advance(time.Second)
+	assert.Equal(t, expire, <-fired)
+}
@@ -0,0 +1,192 @@
+package timer
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/MaxnSter/gnet/clock"
+	"github.com/pkg/errors"
+)
+
+// Mode 决定Every的周期任务如何计算下一次到期时间
+type Mode int
+
+const (
+	// FixedRate 下一次到期时间为上一次的到期时间加上interval, 不随callback的执行时间漂移,
+	// 落后超过一个interval时跳过错过的执行
+	FixedRate Mode = iota
+	// FixedDelay 下一次到期时间为上一次callback执行完毕的时间加上interval
+	FixedDelay
+)
+
+// ScheduleOption 是Every与Cron的配置
+type ScheduleOption struct {
+	// Mode 周期任务的模式, 默认为FixedRate, 对Cron无效
+	Mode Mode
+
+	// MaxRuns 最多执行的次数, 0表示不限制
+	MaxRuns int
+
+	// Jitter 每次到期时间随机推迟[0, Jitter), 避免大量定时任务同时到期.
+	// 抖动不会累积到之后的到期时间上
+	Jitter time.Duration
+
+	// Clock 计算到期时间使用的时间, 需要与Timer使用的时间一致, 默认为clock.Real
+	Clock clock.Clock
+}
+
+func WithMode(m Mode) func(*ScheduleOption) {
+	return func(o *ScheduleOption) {
+		o.Mode = m
+	}
+}
+
+func WithMaxRuns(n int) func(*ScheduleOption) {
+	return func(o *ScheduleOption) {
+		o.MaxRuns = n
+	}
+}
+
+func WithJitter(d time.Duration) func(*ScheduleOption) {
+	return func(o *ScheduleOption) {
+		o.Jitter = d
+	}
+}
+
+func WithClock(c clock.Clock) func(*ScheduleOption) {
+	return func(o *ScheduleOption) {
+		o.Clock = c
+	}
+}
+
+// Every 在t上添加一个每隔interval执行一次cb的周期任务, 第一次在interval之后执行
+func Every(t Timer, interval time.Duration, cb OnTimeOut, opts ...func(*ScheduleOption)) Cancel {
+	if interval <= 0 {
+		panic("non-positive interval for timer.Every")
+	}
+
+	s := newSchedule(t, cb, opts...)
+	s.next = func(scheduled, now time.Time) time.Time {
+		if s.opt.Mode == FixedDelay {
+			return now.Add(interval)
+		}
+
+		next := scheduled.Add(interval)
+		if !next.After(now) {
+			next = next.Add((now.Sub(next)/interval + 1) * interval)
+		}
+		return next
+	}
+
+	s.start(s.opt.Clock.Now().Add(interval))
+	return s.Cancel
+}
+
+// Cron 在t上添加一个按照cron表达式执行cb的周期任务, 表达式的格式见ParseCron
+func Cron(t Timer, spec string, cb OnTimeOut, opts ...func(*ScheduleOption)) (Cancel, error) {
+	c, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSchedule(t, cb, opts...)
+	s.opt.Mode = FixedRate
+	s.next = func(scheduled, now time.Time) time.Time {
+		if now.Before(scheduled) {
+			now = scheduled
+		}
+		return c.Next(now)
+	}
+
+	first := c.Next(s.opt.Clock.Now())
+	if first.IsZero() {
+		return nil, errors.Errorf("cron spec %q never matches", spec)
+	}
+	s.start(first)
+	return s.Cancel, nil
+}
+
+// schedule 使用一次性的定时任务串联起一个周期任务
+type schedule struct {
+	t    Timer
+	opt  ScheduleOption
+	cb   OnTimeOut
+	next func(scheduled, now time.Time) time.Time
+
+	guard   sync.Mutex
+	cancel  Cancel
+	runs    int
+	stopped bool
+}
+
+func newSchedule(t Timer, cb OnTimeOut, opts ...func(*ScheduleOption)) *schedule {
+	s := &schedule{t: t, cb: cb}
+	for _, f := range opts {
+		f(&s.opt)
+	}
+	s.opt.Clock = clock.OrReal(s.opt.Clock)
+	return s
+}
+
+func (s *schedule) start(expire time.Time) {
+	s.guard.Lock()
+	s.arm(expire)
+	s.guard.Unlock()
+}
+
+// arm 添加下一次执行的定时任务, 调用方持有guard
+func (s *schedule) arm(scheduled time.Time) {
+	if scheduled.IsZero() {
+		s.stopped = true
+		return
+	}
+
+	expire := scheduled
+	if s.opt.Jitter > 0 {
+		expire = expire.Add(time.Duration(rand.Int63n(int64(s.opt.Jitter))))
+	}
+	s.cancel = s.t.AddTimer(expire, 0, func(now time.Time) {
+		s.fire(scheduled, now)
+	})
+}
+
+func (s *schedule) fire(scheduled, now time.Time) {
+	s.guard.Lock()
+	if s.stopped {
+		s.guard.Unlock()
+		return
+	}
+
+	s.runs++
+	if s.opt.MaxRuns > 0 && s.runs >= s.opt.MaxRuns {
+		s.stopped = true
+	}
+	delay := s.opt.Mode == FixedDelay
+	if !s.stopped && !delay {
+		s.arm(s.next(scheduled, now))
+	}
+	s.guard.Unlock()
+
+	s.cb(now)
+
+	if delay {
+		s.guard.Lock()
+		if !s.stopped {
+			s.arm(s.next(scheduled, s.opt.Clock.Now()))
+		}
+		s.guard.Unlock()
+	}
+}
+
+// Cancel 取消周期任务, 可以多次调用
+func (s *schedule) Cancel() {
+	s.guard.Lock()
+	s.stopped = true
+	cancel := s.cancel
+	s.guard.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
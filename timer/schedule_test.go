@@ -0,0 +1,129 @@
+package timer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MaxnSter/gnet/clock"
+	"github.com/MaxnSter/gnet/pool/plugins/pool_race_other"
+	"github.com/MaxnSter/gnet/timer"
+	"github.com/MaxnSter/gnet/timer/plugins/timer_heap"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTimer(c clock.Clock) (timer.Timer, func()) {
+	p := pool_race_other.New()
+	p.Run()
+
+	t := timer_heap.New(timer_heap.WithClock(c))
+	t.SetPool(p)
+	t.Run()
+	return t, func() {
+		t.Stop()
+		p.Stop()
+	}
+}
+
+// armTimer 在每次添加定时任务时通知测试, 用于在推进时间之前确认下一次执行已经添加
+type armTimer struct {
+	timer.Timer
+	armed chan time.Time
+}
+
+func (t *armTimer) AddTimer(expire time.Time, interval time.Duration, cb timer.OnTimeOut) timer.Cancel {
+	cancel := t.Timer.AddTimer(expire, interval, cb)
+	t.armed <- expire
+	return cancel
+}
+
+// expectNone 确认fired在一段时间内没有收到任何执行, callback通过pool异步执行, 不能立即检查
+func expectNone(t *testing.T, fired <-chan time.Time) {
+	select {
+	case now := <-fired:
+		t.Errorf("unexpected run at %s", now)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEvery_FixedRate(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	tm, stop := newTestTimer(fake)
+	defer stop()
+
+	fired := make(chan time.Time, 4)
+	start := fake.Now()
+	cancel := timer.Every(tm, time.Second, func(now time.Time) {
+		fired <- now
+	}, timer.WithClock(fake), timer.WithMaxRuns(3))
+	defer cancel()
+
+	// 到期时间不随执行时间漂移
+	fake.Advance(1500 * time.Millisecond)
+	assert.Equal(t, start.Add(1500*time.Millisecond), <-fired)
+	fake.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(2*time.Second), <-fired)
+
+	// 落后时跳过错过的执行
+	fake.Advance(2500 * time.Millisecond)
+	assert.Equal(t, start.Add(4500*time.Millisecond), <-fired)
+
+	// 达到MaxRuns后不再执行
+	fake.Advance(10 * time.Second)
+	expectNone(t, fired)
+}
+
+func TestEvery_FixedDelay(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	base, stop := newTestTimer(fake)
+	defer stop()
+	tm := &armTimer{Timer: base, armed: make(chan time.Time, 4)}
+
+	fired := make(chan time.Time, 4)
+	start := fake.Now()
+	cancel := timer.Every(tm, time.Second, func(now time.Time) {
+		// callback执行了500ms
+		fake.Advance(500 * time.Millisecond)
+		fired <- now
+	}, timer.WithClock(fake), timer.WithMode(timer.FixedDelay))
+	assert.Equal(t, start.Add(time.Second), <-tm.armed)
+
+	fake.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-fired)
+
+	// 下一次在callback结束(1.5s)之后1s, callback返回之后才添加
+	assert.Equal(t, start.Add(2500*time.Millisecond), <-tm.armed)
+	fake.Advance(900 * time.Millisecond)
+	expectNone(t, fired)
+	fake.Advance(100 * time.Millisecond)
+	assert.Equal(t, start.Add(2500*time.Millisecond), <-fired)
+	assert.Equal(t, start.Add(4*time.Second), <-tm.armed)
+
+	cancel()
+	fake.Advance(10 * time.Second)
+	expectNone(t, fired)
+}
+
+func TestCron(t *testing.T) {
+	fake := clock.NewFake(time.Date(2019, 6, 3, 10, 15, 30, 0, time.UTC))
+	tm, stop := newTestTimer(fake)
+	defer stop()
+
+	fired := make(chan time.Time, 4)
+	cancel, err := timer.Cron(tm, "*/20 * * * *", func(now time.Time) {
+		fired <- now
+	}, timer.WithClock(fake), timer.WithJitter(time.Second))
+	assert.Nil(t, err)
+	defer cancel()
+
+	// 10:20:00之后的1s之内到期
+	fake.Advance(4*time.Minute + 29*time.Second)
+	expectNone(t, fired)
+	fake.Advance(2 * time.Second)
+	assert.Equal(t, time.Date(2019, 6, 3, 10, 20, 1, 0, time.UTC), <-fired)
+
+	fake.Advance(20 * time.Minute)
+	assert.Equal(t, time.Date(2019, 6, 3, 10, 40, 1, 0, time.UTC), <-fired)
+
+	_, err = timer.Cron(tm, "0 0 30 2 *", func(time.Time) {}, timer.WithClock(fake))
+	assert.NotNil(t, err)
+}
@@ -3,6 +3,7 @@ package timer
 import (
 	"time"
 
+	"github.com/MaxnSter/gnet/clock"
 	"github.com/MaxnSter/gnet/pool"
 )
 
@@ -19,3 +20,24 @@ type Timer interface {
 
 	String() string
 }
+
+// Handle 是一个定时任务的句柄, 定时任务结束(一次性任务到期或被取消)后,
+// 句柄随之失效, 不会影响之后添加的其他定时任务
+type Handle interface {
+	// Cancel 取消定时任务, 可以多次调用
+	Cancel()
+
+	// Reset 将定时任务的下一次到期时间改为expire, 定时任务已经结束时返回false
+	Reset(expire time.Time) bool
+}
+
+// ClockSetter 由可以更换时间的Timer实现, module设置了clock时运行前通过SetClock传给timer,
+// SetClock需要在Run与AddTimer之前调用
+type ClockSetter interface {
+	SetClock(clock.Clock)
+}
+
+// Scheduler 由可以返回Handle的Timer实现
+type Scheduler interface {
+	Schedule(expire time.Time, interval time.Duration, cb OnTimeOut) Handle
+}
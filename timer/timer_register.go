@@ -1,14 +1,23 @@
 package timer
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
 
 type timerCreator func() Timer
 
 var (
-	timerCreators map[string]timerCreator
+	timerCreators = map[string]timerCreator{}
 )
 
 func RegisterTimer(name string, t timerCreator) {
+	if _, ok := timerCreators[name]; ok {
+		panic("duplicate register timer :" + name)
+	}
+
 	timerCreators[name] = t
 }
 
@@ -19,3 +28,22 @@ func MustGetTimer(name string) Timer {
 
 	return timerCreators[name]()
 }
+
+// GetTimer 创建一个指定名字对应的timer, 若未注册,返回错误
+func GetTimer(name string) (Timer, error) {
+	if creator, ok := timerCreators[name]; ok {
+		return creator(), nil
+	}
+
+	return nil, errors.Errorf("timer:%s, not register", name)
+}
+
+// ListTimers 返回所有已注册的timer名称,按名称排序
+func ListTimers() []string {
+	names := make([]string, 0, len(timerCreators))
+	for name := range timerCreators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
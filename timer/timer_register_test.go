@@ -0,0 +1,20 @@
+package timer_test
+
+import (
+	"testing"
+
+	"github.com/MaxnSter/gnet/timer"
+	"github.com/MaxnSter/gnet/timer/plugins/timer_heap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTimer(t *testing.T) {
+	assert.Contains(t, timer.ListTimers(), timer_heap.Name)
+
+	tm, err := timer.GetTimer(timer_heap.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, timer_heap.Name, tm.String())
+
+	_, err = timer.GetTimer("not_exist")
+	assert.NotNil(t, err)
+}